@@ -0,0 +1,181 @@
+package gomdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrTxNotSupported is returned when a transaction is needed but the Client's
+// DB can neither begin one nor is a *sql.Tx.
+var ErrTxNotSupported = errors.New("DB must implement BeginTx(context.Context, *sql.TxOptions) (*sql.Tx, error) or be a *sql.Tx to run transactions")
+
+// Rows is the result of a query made through a Backend.
+type Rows interface {
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+	Close() error
+}
+
+// Backend executes Message DB procedures on behalf of a Client. NewClient uses
+// a Backend built on database/sql, other drivers can be used by implementing
+// Backend and calling NewClientWithBackend.
+type Backend interface {
+	// Query executes a query that returns rows.
+	Query(ctx context.Context, query string, args ...interface{}) (Rows, error)
+	// BeginTx starts a transaction. A Backend that is already bound to a
+	// transaction may instead join it, or start a nested transaction.
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (TxBackend, error)
+}
+
+// TxBackend is a Backend bound to a single transaction.
+type TxBackend interface {
+	Backend
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+}
+
+// txBeginner is implemented by DBs that can start a new transaction, such as
+// *sql.DB and *sql.Conn.
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// sqlBackend is the database/sql Backend. Statements are prepared once per
+// query and reused until the backend is closed.
+type sqlBackend struct {
+	db    DB
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+func newSQLBackend(db DB) *sqlBackend {
+	return &sqlBackend{
+		db:    db,
+		stmts: map[string]*sql.Stmt{},
+	}
+}
+
+func (b *sqlBackend) Query(ctx context.Context, query string, args ...interface{}) (Rows, error) {
+	stmt, err := b.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt.QueryContext(ctx, args...)
+}
+
+// prepare returns the cached statement for the query, preparing it if this is
+// the first time it has been used.
+func (b *sqlBackend) prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	b.mu.Lock()
+	stmt, ok := b.stmts[query]
+	b.mu.Unlock()
+
+	if ok {
+		return stmt, nil
+	}
+
+	stmt, err := b.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("preparing statement: %w", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// another goroutine may have prepared the same query concurrently.
+	if existing, ok := b.stmts[query]; ok {
+		_ = stmt.Close()
+		return existing, nil
+	}
+
+	b.stmts[query] = stmt
+
+	return stmt, nil
+}
+
+// BeginTx starts a new transaction. If the DB is a *sql.Tx then the returned
+// TxBackend joins that transaction and leaves committing it to its owner. Any
+// other DB that cannot begin a transaction returns ErrTxNotSupported.
+func (b *sqlBackend) BeginTx(ctx context.Context, opts *sql.TxOptions) (TxBackend, error) {
+	beginner, ok := b.db.(txBeginner)
+	if !ok {
+		if _, ok := b.db.(*sql.Tx); ok {
+			return &sqlTxBackend{parent: b}, nil
+		}
+
+		return nil, ErrTxNotSupported
+	}
+
+	tx, err := beginner.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqlTxBackend{parent: b, tx: tx, owned: true}, nil
+}
+
+// Close closes all of the prepared statements.
+func (b *sqlBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var firstErr error
+	for query, stmt := range b.stmts {
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(b.stmts, query)
+	}
+
+	return firstErr
+}
+
+// sqlTxBackend is a sqlBackend bound to a transaction. If owned is false then
+// the backend has joined a transaction owned by someone else, and committing
+// is left to them.
+type sqlTxBackend struct {
+	parent *sqlBackend
+	tx     *sql.Tx
+	owned  bool
+}
+
+// Query executes the parent's prepared statement within the transaction.
+func (b *sqlTxBackend) Query(ctx context.Context, query string, args ...interface{}) (Rows, error) {
+	if b.tx == nil {
+		return b.parent.Query(ctx, query, args...)
+	}
+
+	stmt, err := b.parent.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	// transaction specific statements are closed when the transaction ends.
+	return b.tx.StmtContext(ctx, stmt).QueryContext(ctx, args...)
+}
+
+// BeginTx joins the existing transaction.
+func (b *sqlTxBackend) BeginTx(ctx context.Context, opts *sql.TxOptions) (TxBackend, error) {
+	return &sqlTxBackend{parent: b.parent, tx: b.tx}, nil
+}
+
+func (b *sqlTxBackend) Commit(ctx context.Context) error {
+	if !b.owned {
+		return nil
+	}
+
+	return b.tx.Commit()
+}
+
+func (b *sqlTxBackend) Rollback(ctx context.Context) error {
+	if !b.owned {
+		return nil
+	}
+
+	return b.tx.Rollback()
+}
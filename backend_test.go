@@ -0,0 +1,41 @@
+package gomdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+// wrappedDB is a DB wrapper, such as an instrumented handle, that does not
+// implement BeginTx.
+type wrappedDB struct {
+	DB
+}
+
+func Test_sqlBackend_BeginTx(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(wrappedDB{})
+	stream := StreamIdentifier{Category: "account", ID: "123"}
+
+	err := client.InTx(context.TODO(), func(tx *TxClient) error {
+		t.Fatal("expected the transaction function not to be called")
+		return nil
+	})
+	if !errors.Is(err, ErrTxNotSupported) {
+		t.Fatalf("expected %v, actual %v", ErrTxNotSupported, err)
+	}
+
+	_, err = client.WriteMessages(context.TODO(), stream, []ProposedMessage{
+		{ID: "00000000-0000-4000-8000-000000000000", Type: "SomeType", Data: "data"},
+	}, AnyVersion)
+	if !errors.Is(err, ErrTxNotSupported) {
+		t.Fatalf("expected %v, actual %v", ErrTxNotSupported, err)
+	}
+
+	// a *sql.Tx is joined rather than rejected.
+	if _, err := newSQLBackend(&sql.Tx{}).BeginTx(context.TODO(), nil); err != nil {
+		t.Fatal(err)
+	}
+}
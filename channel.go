@@ -0,0 +1,185 @@
+package gomdb
+
+import (
+	"context"
+	"fmt"
+)
+
+// DefaultChannelBuffer is the default number of messages buffered by a
+// ChannelSubscription.
+const DefaultChannelBuffer = int64(100)
+
+// SubscriptionEvent is sent on the Events channel of a ChannelSubscription. It
+// is either a LivenessChanged or a SubscriptionDropped.
+type SubscriptionEvent interface {
+	subscriptionEvent()
+}
+
+// LivenessChanged is sent when a subscription catches up (Live is true) or
+// falls behind again (Live is false).
+type LivenessChanged struct {
+	Live bool
+}
+
+// SubscriptionDropped is the last event sent before a subscription's channels
+// are closed. Err is the error that stopped the subscription, or nil if it was
+// stopped or cancelled.
+type SubscriptionDropped struct {
+	Err error
+}
+
+func (LivenessChanged) subscriptionEvent()     {}
+func (SubscriptionDropped) subscriptionEvent() {}
+
+// ChannelSubscription is a subscription that delivers messages on a channel.
+// When the message buffer is full the subscription stops polling until the
+// consumer reads from the channel again.
+type ChannelSubscription struct {
+	*Subscription
+	cancel   context.CancelFunc
+	messages chan *Message
+	events   chan SubscriptionEvent
+}
+
+// Messages returns the channel that messages are delivered on. It is closed
+// once the subscription has exited.
+func (s *ChannelSubscription) Messages() <-chan *Message {
+	return s.messages
+}
+
+// Events returns the channel that liveness changes and the final
+// SubscriptionDropped are sent on. Events are never waited on: if the consumer
+// falls behind then older liveness changes are discarded in favour of newer
+// ones. It is closed once the subscription has exited.
+func (s *ChannelSubscription) Events() <-chan SubscriptionEvent {
+	return s.events
+}
+
+// Stop stops the subscription, including when it is waiting for the consumer
+// to read a message. Messages still buffered in the channel can be read until
+// it is closed.
+func (s *ChannelSubscription) Stop() {
+	s.cancel()
+}
+
+// SubscribeToStreamChan subscribes to a stream and delivers its messages on a
+// channel, which buffers 100 messages by default. Use
+// WithStreamChannelBuffer to change the buffer size. Messages count as handled
+// once they are sent on the channel, so a PositionStore records messages that
+// have been buffered but not yet read. Message handler, batch handler and
+// failure policy options are ignored.
+func (c *Client) SubscribeToStreamChan(ctx context.Context, stream StreamIdentifier, opts ...GetStreamOption) (*ChannelSubscription, error) {
+	cfg := newDefaultStreamConfig(c.defaultPollingStrat())
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("validating options: %w", err)
+	}
+
+	ctx, cs := newChannelSubscription(ctx, cfg.channelBuffer)
+
+	sub, err := c.SubscribeToStream(ctx, stream, nil, cs.handleLiveness, cs.handleDropped, append(opts,
+		WithStreamMessageHandlerE(cs.handleMessage(ctx)),
+		WithStreamBatchHandler(nil, 0),
+		WithStreamFailurePolicy(StopOnFailure()),
+	)...)
+	if err != nil {
+		cs.cancel()
+		return nil, err
+	}
+
+	cs.Subscription = sub
+
+	return cs, nil
+}
+
+// SubscribeToCategoryChan subscribes to a category and delivers its messages
+// on a channel, which buffers 100 messages by default. Use
+// WithCategoryChannelBuffer to change the buffer size. Messages count as
+// handled once they are sent on the channel, so a PositionStore records
+// messages that have been buffered but not yet read. Message handler, batch
+// handler and failure policy options are ignored.
+func (c *Client) SubscribeToCategoryChan(ctx context.Context, category string, opts ...GetCategoryOption) (*ChannelSubscription, error) {
+	cfg := newDefaultCategoryConfig(c.defaultPollingStrat())
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("validating options: %w", err)
+	}
+
+	ctx, cs := newChannelSubscription(ctx, cfg.channelBuffer)
+
+	sub, err := c.SubscribeToCategory(ctx, category, nil, cs.handleLiveness, cs.handleDropped, append(opts,
+		WithCategoryMessageHandlerE(cs.handleMessage(ctx)),
+		WithCategoryBatchHandler(nil, 0),
+		WithCategoryFailurePolicy(StopOnFailure()),
+	)...)
+	if err != nil {
+		cs.cancel()
+		return nil, err
+	}
+
+	cs.Subscription = sub
+
+	return cs, nil
+}
+
+// newChannelSubscription returns a ChannelSubscription and the context that it
+// is stopped with.
+func newChannelSubscription(ctx context.Context, buffer int64) (context.Context, *ChannelSubscription) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	return ctx, &ChannelSubscription{
+		cancel:   cancel,
+		messages: make(chan *Message, buffer),
+		// room for a liveness change and the dropped event.
+		events: make(chan SubscriptionEvent, 2),
+	}
+}
+
+// handleMessage returns a handler that blocks until the message is sent or
+// the context is cancelled.
+func (s *ChannelSubscription) handleMessage(ctx context.Context) MessageHandlerE {
+	return func(msg *Message) error {
+		select {
+		case s.messages <- msg:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *ChannelSubscription) handleLiveness(live bool) {
+	s.sendEvent(LivenessChanged{Live: live})
+}
+
+func (s *ChannelSubscription) handleDropped(err error) {
+	s.sendEvent(SubscriptionDropped{Err: err})
+
+	close(s.events)
+	close(s.messages)
+	s.cancel()
+}
+
+// sendEvent sends the event without blocking, discarding the oldest unread
+// event to make room if necessary. Events are only sent from the subscription
+// goroutine, so there is always room after discarding.
+func (s *ChannelSubscription) sendEvent(event SubscriptionEvent) {
+	select {
+	case s.events <- event:
+		return
+	default:
+	}
+
+	select {
+	case <-s.events:
+	default:
+	}
+
+	s.events <- event
+}
@@ -0,0 +1,72 @@
+package gomdb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_ChannelSubscription(t *testing.T) {
+	t.Parallel()
+
+	ctx, cs := newChannelSubscription(context.TODO(), 1)
+	ctx, cancel := context.WithCancel(ctx)
+	cs.Subscription = newSubscription(cancel, 0)
+
+	loop := testLoop(testMessages(10), 4)
+	loop.handleMessage = cs.handleMessage(ctx)
+	loop.handleLiveness = cs.handleLiveness
+	loop.handleDropped = cs.handleDropped
+
+	go (&Client{}).runSubscription(ctx, cs.Subscription, loop)
+
+	// the first message fills the buffer and the second blocks.
+	time.Sleep(50 * time.Millisecond)
+
+	if cs.Position() != 1 {
+		t.Fatalf("expected subscription to wait at position 1, actual %v", cs.Position())
+	}
+
+	for i := int64(0); i < 10; i++ {
+		if msg := <-cs.Messages(); msg.Version != i {
+			t.Fatalf("expected message %v, actual %v", i, msg.Version)
+		}
+	}
+
+	if event := <-cs.Events(); event != (LivenessChanged{Live: true}) {
+		t.Fatalf("expected to go live, actual %#v", event)
+	}
+
+	cs.Stop()
+
+	if event := <-cs.Events(); event != (SubscriptionDropped{}) {
+		t.Fatalf("expected to be dropped without error, actual %#v", event)
+	} else if _, ok := <-cs.Messages(); ok {
+		t.Fatal("expected messages channel to be closed")
+	} else if err := cs.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_ChannelSubscription_sendEvent(t *testing.T) {
+	t.Parallel()
+
+	_, cs := newChannelSubscription(context.TODO(), 0)
+
+	// unread liveness changes are discarded, oldest first.
+	cs.handleLiveness(true)
+	cs.handleLiveness(false)
+	cs.handleLiveness(true)
+	cs.handleDropped(nil)
+
+	expected := []SubscriptionEvent{LivenessChanged{Live: true}, SubscriptionDropped{}}
+	for _, exp := range expected {
+		if event := <-cs.Events(); event != exp {
+			t.Fatalf("expected %#v, actual %#v", exp, event)
+		}
+	}
+
+	if _, ok := <-cs.Events(); ok {
+		t.Fatal("expected events channel to be closed")
+	}
+}
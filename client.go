@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 )
@@ -25,16 +26,59 @@ const (
 // version when writing a message.
 var ErrUnexpectedStreamVersion = errors.New("unexpected stream version when writing message")
 
+// ErrMissingMessages is returned when attempting to write an empty batch of
+// messages.
+var ErrMissingMessages = errors.New("at least one message must be proposed")
+
+// MessageLimitError is returned by ReadAllStreamMessages when a stream holds
+// more messages than the configured message limit.
+type MessageLimitError struct {
+	Stream StreamIdentifier
+	Limit  int64
+}
+
+// Error implements the error interface.
+func (e *MessageLimitError) Error() string {
+	return fmt.Sprintf("stream %s exceeds message limit of %v", e.Stream, e.Limit)
+}
+
+// DB is the database handle used by a Client to call Message DB procedures.
+// It is satisfied by *sql.DB, *sql.Tx and *sql.Conn, as well as by any wrapper
+// around them. Wrappers must also implement
+// BeginTx(context.Context, *sql.TxOptions) (*sql.Tx, error) to be used with
+// WriteMessages, InTx and ReadAllStreamMessages, which run in a transaction.
+type DB interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
 // Client exposes the message-db interface.
 type Client struct {
-	db                  *sql.DB
+	backend             Backend
 	defaultPollingStrat func() PollingStrategy
+	notifications       *notificationHub
+}
+
+// NewClient returns a new message-db client for the provided database. SQL
+// statements are prepared on first use and reused until the Client is closed.
+// When the database is a *sql.Tx all calls made by the Client happen within
+// that transaction, and committing it is left to the caller.
+func NewClient(db DB, opts ...ClientOption) *Client {
+	return NewClientWithBackend(newSQLBackend(db), opts...)
 }
 
-// NewClient returns a new message-db client for the provided database.
-func NewClient(db *sql.DB, opts ...ClientOption) *Client {
+// NewSQLBackend returns the Backend that NewClient uses for the provided
+// database, so that it can be wrapped and passed to NewClientWithBackend.
+func NewSQLBackend(db DB) Backend {
+	return newSQLBackend(db)
+}
+
+// NewClientWithBackend returns a new message-db client that calls Message DB
+// procedures through the provided Backend.
+func NewClientWithBackend(backend Backend, opts ...ClientOption) *Client {
 	c := &Client{
-		db: db,
+		backend: backend,
 		// default polling strategy is used for new subscriptions that don't
 		// specify their own polling strategy.
 		defaultPollingStrat: ConstantPolling(DefaultPollingInterval),
@@ -47,6 +91,16 @@ func NewClient(db *sql.DB, opts ...ClientOption) *Client {
 	return c
 }
 
+// Close releases any resources held by the Client, such as prepared
+// statements. The underlying database is not closed.
+func (c *Client) Close() error {
+	if closer, ok := c.backend.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
 // WriteMessage attempted to write the proposed message to the specifed stream.
 func (c *Client) WriteMessage(ctx context.Context, stream StreamIdentifier, message ProposedMessage, expectedVersion int64) (int64, error) {
 	// validate inputs
@@ -56,6 +110,49 @@ func (c *Client) WriteMessage(ctx context.Context, stream StreamIdentifier, mess
 		return 0, fmt.Errorf("validating message: %w", err)
 	}
 
+	return c.writeMessage(ctx, stream, message, expectedVersion)
+}
+
+// WriteMessages attempts to write all of the proposed messages to the
+// specified stream within a single transaction. The expected version applies
+// to the first message and is advanced for each message after it. If any
+// message fails to be written then none of the messages are written. The
+// version of the last written message is returned.
+func (c *Client) WriteMessages(ctx context.Context, stream StreamIdentifier, messages []ProposedMessage, expectedVersion int64) (int64, error) {
+	// validate inputs
+	if err := stream.validate(); err != nil {
+		return 0, fmt.Errorf("validating stream identifier: %w", err)
+	} else if len(messages) == 0 {
+		return 0, ErrMissingMessages
+	}
+
+	for i := range messages {
+		if err := messages[i].validate(); err != nil {
+			return 0, fmt.Errorf("validating message %v: %w", i, err)
+		}
+	}
+
+	var version int64
+
+	err := c.withTx(ctx, nil, func(tc *Client) error {
+		version = expectedVersion
+		for _, message := range messages {
+			var err error
+			if version, err = tc.writeMessage(ctx, stream, message, version); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+func (c *Client) writeMessage(ctx context.Context, stream StreamIdentifier, message ProposedMessage, expectedVersion int64) (int64, error) {
 	// Marshal data and metadata.
 	data, err := json.Marshal(message.Data)
 	if err != nil {
@@ -73,18 +170,10 @@ func (c *Client) WriteMessage(ctx context.Context, stream StreamIdentifier, mess
 		ev = nil
 	}
 
-	// prepare and execute query.
-	stmt, err := c.db.PrepareContext(ctx, WriteMessageSQL)
+	// execute query.
+	rows, err := c.backend.Query(ctx, WriteMessageSQL, message.ID, stream.String(), message.Type, data, metadata, ev)
 	if err != nil {
-		return 0, fmt.Errorf("preparing write statement: %w", err)
-	}
-
-	rows, err := stmt.QueryContext(ctx, message.ID, stream.String(), message.Type, data, metadata, ev)
-	if err != nil {
-		if strings.Contains(err.Error(), "Wrong expected version") {
-			return 0, ErrUnexpectedStreamVersion
-		}
-		return 0, fmt.Errorf("executing write statement: %w", err)
+		return 0, writeError(err)
 	}
 
 	defer rows.Close()
@@ -93,6 +182,9 @@ func (c *Client) WriteMessage(ctx context.Context, stream StreamIdentifier, mess
 	var version int64
 
 	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return 0, writeError(err)
+		}
 		return 0, errors.New("write succeeded but no rows were returned")
 	}
 
@@ -103,6 +195,15 @@ func (c *Client) WriteMessage(ctx context.Context, stream StreamIdentifier, mess
 	return version, nil
 }
 
+// writeError maps errors raised by the write_message procedure.
+func writeError(err error) error {
+	if strings.Contains(err.Error(), "Wrong expected version") {
+		return ErrUnexpectedStreamVersion
+	}
+
+	return fmt.Errorf("executing write statement: %w", err)
+}
+
 // GetStreamMessages reads messages from an individual stream. By default the
 // stream is read from the beginning with a batch size of 1000. Use
 // GetStreamOptions to adjust this behaviour.
@@ -119,13 +220,8 @@ func (c *Client) GetStreamMessages(ctx context.Context, stream StreamIdentifier,
 		return nil, fmt.Errorf("validating options: %w", err)
 	}
 
-	// prepare and execute query.
-	stmt, err := c.db.PrepareContext(ctx, GetStreamMessagesSQL)
-	if err != nil {
-		return nil, fmt.Errorf("preparing get stream statement: %w", err)
-	}
-
-	rows, err := stmt.QueryContext(ctx, stream.String(), cfg.version, cfg.batchSize, cfg.getCondition())
+	// execute query.
+	rows, err := c.backend.Query(ctx, GetStreamMessagesSQL, stream.String(), cfg.version, cfg.batchSize, cfg.getCondition())
 	if err != nil {
 		return nil, fmt.Errorf("executing get stream statement: %w", err)
 	}
@@ -144,6 +240,55 @@ func (c *Client) GetStreamMessages(ctx context.Context, stream StreamIdentifier,
 		msgs = append(msgs, msg)
 	}
 
+	if err = rows.Err(); err != nil {
+		return msgs, fmt.Errorf("executing get stream statement: %w", err)
+	}
+
+	return msgs, nil
+}
+
+// ReadAllStreamMessages reads every message in a stream, from the beginning or
+// from the version specified with FromVersion, up to the current head of the
+// stream. All batches are read within a single REPEATABLE READ transaction so
+// the result is a consistent snapshot of the stream. When the Client was
+// created over a *sql.Tx or pgx.Tx the batches are read in that transaction
+// instead, using its isolation level, so the result is only a consistent
+// snapshot if that transaction is REPEATABLE READ or SERIALIZABLE. Use
+// WithStreamMessageLimit to cap the number of messages read, a
+// *MessageLimitError is returned if the stream exceeds the limit.
+func (c *Client) ReadAllStreamMessages(ctx context.Context, stream StreamIdentifier, opts ...GetStreamOption) ([]*Message, error) {
+	cfg := newDefaultStreamConfig(c.defaultPollingStrat())
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	// validate inputs
+	if err := stream.validate(); err != nil {
+		return nil, fmt.Errorf("validating stream identifier: %w", err)
+	} else if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("validating options: %w", err)
+	}
+
+	var msgs []*Message
+
+	err := c.withTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}, func(tc *Client) error {
+		msgs = []*Message{}
+
+		it := tc.IterateStream(ctx, stream, func(c *streamConfig) { *c = *cfg })
+		for it.Next() {
+			if cfg.messageLimit > 0 && int64(len(msgs)) == cfg.messageLimit {
+				return &MessageLimitError{Stream: stream, Limit: cfg.messageLimit}
+			}
+
+			msgs = append(msgs, it.Message())
+		}
+
+		return it.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	return msgs, nil
 }
 
@@ -158,19 +303,14 @@ func (c *Client) GetCategoryMessages(ctx context.Context, category string, opts
 	}
 
 	// validate inputs
-	if strings.Contains(category, StreamNameSeparator) {
-		return nil, fmt.Errorf("category cannot contain stream name separator (%s)", StreamNameSeparator)
+	if err := validateCategory(category); err != nil {
+		return nil, fmt.Errorf("validating category: %w", err)
 	} else if err := cfg.validate(); err != nil {
 		return nil, fmt.Errorf("validating options: %w", err)
 	}
 
-	// prepare and execute query.
-	stmt, err := c.db.PrepareContext(ctx, GetCategoryMessagesSQL)
-	if err != nil {
-		return nil, fmt.Errorf("preparing get stream statement: %w", err)
-	}
-
-	rows, err := stmt.QueryContext(ctx, category, cfg.position, cfg.batchSize, cfg.getCorrelation(), cfg.getConsumerGroupMember(), cfg.getConsumerGroupSize(), cfg.getCondition())
+	// execute query.
+	rows, err := c.backend.Query(ctx, GetCategoryMessagesSQL, category, cfg.position, cfg.batchSize, cfg.getCorrelation(), cfg.getConsumerGroupMember(), cfg.getConsumerGroupSize(), cfg.getCondition())
 	if err != nil {
 		return nil, fmt.Errorf("executing get stream statement: %w", err)
 	}
@@ -189,6 +329,10 @@ func (c *Client) GetCategoryMessages(ctx context.Context, category string, opts
 		msgs = append(msgs, msg)
 	}
 
+	if err = rows.Err(); err != nil {
+		return msgs, fmt.Errorf("executing get stream statement: %w", err)
+	}
+
 	return msgs, nil
 }
 
@@ -200,13 +344,8 @@ func (c *Client) GetLastStreamMessage(ctx context.Context, stream StreamIdentifi
 		return nil, fmt.Errorf("validating stream identifier: %w", err)
 	}
 
-	// prepare and execute query.
-	stmt, err := c.db.PrepareContext(ctx, GetLastStreamMessageSQL)
-	if err != nil {
-		return nil, fmt.Errorf("preparing get stream statement: %w", err)
-	}
-
-	rows, err := stmt.QueryContext(ctx, stream.String())
+	// execute query.
+	rows, err := c.backend.Query(ctx, GetLastStreamMessageSQL, stream.String())
 	if err != nil {
 		return nil, fmt.Errorf("executing get stream statement: %w", err)
 	}
@@ -214,6 +353,9 @@ func (c *Client) GetLastStreamMessage(ctx context.Context, stream StreamIdentifi
 	defer rows.Close()
 
 	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, fmt.Errorf("executing get stream statement: %w", err)
+		}
 		return nil, nil
 	}
 
@@ -233,13 +375,8 @@ func (c *Client) GetStreamVersion(ctx context.Context, stream StreamIdentifier)
 		return 0, fmt.Errorf("validating stream identifier: %w", err)
 	}
 
-	// prepare and execute query.
-	stmt, err := c.db.PrepareContext(ctx, GetStreamVersionSQL)
-	if err != nil {
-		return 0, fmt.Errorf("preparing get stream version statement: %w", err)
-	}
-
-	rows, err := stmt.QueryContext(ctx, stream.String())
+	// execute query.
+	rows, err := c.backend.Query(ctx, GetStreamVersionSQL, stream.String())
 	if err != nil {
 		return 0, fmt.Errorf("executing get stream version statement: %w", err)
 	}
@@ -249,6 +386,9 @@ func (c *Client) GetStreamVersion(ctx context.Context, stream StreamIdentifier)
 	// read version from results.
 
 	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return 0, fmt.Errorf("executing get stream version statement: %w", err)
+		}
 		return 0, errors.New("no rows were returned")
 	}
 
@@ -266,186 +406,73 @@ func (c *Client) GetStreamVersion(ctx context.Context, stream StreamIdentifier)
 	return 0, fmt.Errorf("unexpected column value type: %T", value)
 }
 
-// MessageHandler handles messages as they appear after being written.
-type MessageHandler func(*Message)
-
-// LivenessHandler handles whether the subscription is in a "live" state or
-// whether it is catching up.
-type LivenessHandler func(bool)
-
-// SubDroppedHandler handles errors that appear and stop the subscription.
-type SubDroppedHandler func(error)
-
-// SubscribeToStream subscribes to a stream and asynchronously passes messages
-// to the message handler in batches. Once a subscription has caught up it will
-// poll the database periodically for new messages. To stop a subscription
-// cancel the provided context.
-// When a subscription catches up it will call the LivenessHandler with true. If
-// the subscription falls behind again it will called the LivenessHandler with
-// false.
-// If there is an error while reading messages then the subscription will be
-// stopped and the SubDroppedHandler will be called with the stopping error. If
-// the subscription is cancelled then the SubDroppedHandler will be called with
-// nil.
-func (c *Client) SubscribeToStream(
-	ctx context.Context,
-	stream StreamIdentifier,
-	handleMessage MessageHandler,
-	handleLiveness LivenessHandler,
-	handleDropped SubDroppedHandler,
-	opts ...GetStreamOption,
-) error {
-	cfg := newDefaultStreamConfig(c.defaultPollingStrat())
-	for _, opt := range opts {
-		opt(cfg)
+// GetHeadPosition returns the global position of the last message written to
+// the message store, or -1 if it is empty.
+func (c *Client) GetHeadPosition(ctx context.Context) (int64, error) {
+	position, err := c.queryInt64(ctx, GetHeadPositionSQL)
+	if err != nil {
+		return 0, fmt.Errorf("executing get head position statement: %w", err)
 	}
 
+	return position, nil
+}
+
+// GetCategoryHeadPosition returns the global position of the last message
+// written to the category, or -1 if the category is empty.
+func (c *Client) GetCategoryHeadPosition(ctx context.Context, category string) (int64, error) {
 	// validate inputs
-	if err := stream.validate(); err != nil {
-		return fmt.Errorf("validating stream identifier: %w", err)
-	} else if handleMessage == nil || handleLiveness == nil || handleDropped == nil {
-		return errors.New("all subscription handlers are required")
-	} else if err := cfg.validate(); err != nil {
-		return fmt.Errorf("validating options: %w", err)
+	if err := validateCategory(category); err != nil {
+		return 0, fmt.Errorf("validating category: %w", err)
 	}
 
-	// ignore context cancelled errors
-	wrappedHandleDropped := func(e error) {
-		if errors.Is(e, context.Canceled) {
-			handleDropped(nil)
-		} else {
-			handleDropped(ctx.Err())
-		}
+	position, err := c.queryInt64(ctx, GetCategoryHeadPositionSQL, category)
+	if err != nil {
+		return 0, fmt.Errorf("executing get category head position statement: %w", err)
 	}
 
-	go func() {
-		poll := time.NewTimer(0)
-		live := false
-		defer poll.Stop()
-
-		for {
-			// check for context cancelled
-			select {
-			case <-ctx.Done():
-				wrappedHandleDropped(ctx.Err())
-				return
-			case <-poll.C:
-			}
-
-			msgs, err := c.GetStreamMessages(ctx, stream, func(c *streamConfig) { *c = *cfg })
-			if err != nil {
-				wrappedHandleDropped(err)
-				return
-			}
-
-			poll.Reset(cfg.pollingStrat(int64(len(msgs)), cfg.batchSize))
+	return position, nil
+}
 
-			for _, msg := range msgs {
-				handleMessage(msg)
-			}
+// countCategoryMessages returns the number of messages in the category from
+// the global position onwards, counting only the consumer group member's
+// messages if the group size is greater than 0. Counting stops at limit
+// messages if it is greater than 0.
+func (c *Client) countCategoryMessages(ctx context.Context, category string, position, member, size, limit int64) (int64, error) {
+	group := categoryConfig{consumerGroupMember: member, consumerGroupSize: size}
 
-			if len(msgs) > 0 {
-				cfg.version = msgs[len(msgs)-1].Version + 1
-			}
+	var max interface{}
+	if limit > 0 {
+		max = limit
+	}
 
-			// if we've read fewer messages than the batch size we must have
-			// caught up and can go live. Otherwise we've fallen behind.
-			if len(msgs) < int(cfg.batchSize) && !live {
-				live = true
-				handleLiveness(live)
-			} else if len(msgs) == int(cfg.batchSize) && live {
-				live = false
-				handleLiveness(live)
-			}
-		}
-	}()
+	count, err := c.queryInt64(ctx, CountCategoryMessagesSQL, category, position, group.getConsumerGroupMember(), group.getConsumerGroupSize(), max)
+	if err != nil {
+		return 0, fmt.Errorf("executing count category messages statement: %w", err)
+	}
 
-	return nil
+	return count, nil
 }
 
-// SubscribeToCategory subscribes to a category and asynchronously passes messages
-// to the message handler in batches. Once a subscription has caught up it will
-// poll the database periodically for new messages. To stop a subscription
-// cancel the provided context.
-// When a subscription catches up it will call the LivenessHandler with true. If
-// the subscription falls behind again it will called the LivenessHandler with
-// false.
-// If there is an error while reading messages then the subscription will be
-// stopped and the SubDroppedHandler will be called with the stopping error. If
-// the subscription is cancelled then the SubDroppedHandler will be called with
-// nil.
-func (c *Client) SubscribeToCategory(
-	ctx context.Context,
-	category string,
-	handleMessage MessageHandler,
-	handleLiveness LivenessHandler,
-	handleDropped SubDroppedHandler,
-	opts ...GetCategoryOption,
-) error {
-	cfg := newDefaultCategoryConfig(c.defaultPollingStrat())
-	for _, opt := range opts {
-		opt(cfg)
+// queryInt64 executes a query that returns a single integer.
+func (c *Client) queryInt64(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	rows, err := c.backend.Query(ctx, query, args...)
+	if err != nil {
+		return 0, err
 	}
 
-	// validate inputs
-	if strings.Contains(category, StreamNameSeparator) {
-		return fmt.Errorf("category cannot contain stream name separator (%s)", StreamNameSeparator)
-	} else if handleMessage == nil || handleLiveness == nil || handleDropped == nil {
-		return errors.New("all subscription handlers are required")
-	} else if err := cfg.validate(); err != nil {
-		return fmt.Errorf("validating options: %w", err)
-	}
+	defer rows.Close()
 
-	// ignore context cancelled errors
-	wrappedHandleDropped := func(e error) {
-		if errors.Is(e, context.Canceled) {
-			handleDropped(nil)
-		} else {
-			handleDropped(ctx.Err())
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return 0, err
 		}
+		return 0, errors.New("no rows were returned")
 	}
 
-	go func() {
-		poll := time.NewTimer(0)
-		live := false
-		defer poll.Stop()
-
-		for {
-			// check for context cancelled
-			select {
-			case <-ctx.Done():
-				wrappedHandleDropped(ctx.Err())
-				return
-			case <-poll.C:
-			}
-
-			msgs, err := c.GetCategoryMessages(ctx, category, func(c *categoryConfig) { *c = *cfg })
-			if err != nil {
-				wrappedHandleDropped(err)
-				return
-			}
-
-			poll.Reset(cfg.pollingStrat(int64(len(msgs)), cfg.batchSize))
-
-			for _, msg := range msgs {
-				handleMessage(msg)
-			}
-
-			if len(msgs) > 0 {
-				cfg.position = msgs[len(msgs)-1].GlobalPosition + 1
-			}
-
-			// if we've read fewer messages than the batch size we must have
-			// caught up and can go live. Otherwise we've fallen behind.
-			if len(msgs) < int(cfg.batchSize) && !live {
-				live = true
-				handleLiveness(live)
-			} else if len(msgs) == int(cfg.batchSize) && live {
-				live = false
-				handleLiveness(live)
-			}
-		}
-	}()
+	var value int64
+	if err = rows.Scan(&value); err != nil {
+		return 0, err
+	}
 
-	return nil
+	return value, rows.Err()
 }
@@ -0,0 +1,40 @@
+package gomdb
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+)
+
+// Hash64 returns the same 64 bit hash of a value as Message DB's hash_64
+// function, which takes the first 64 bits of the value's MD5 hash as a signed
+// integer.
+func Hash64(value string) int64 {
+	sum := md5.Sum([]byte(value))
+
+	return int64(binary.BigEndian.Uint64(sum[:8]))
+}
+
+// ConsumerGroupMemberFor returns the consumer group member that will receive
+// the messages of a stream when reading its category as a consumer group of
+// the given size. This matches the server side assignment, which hashes the
+// stream's cardinal ID:
+//
+//	MOD(@hash_64(cardinal_id(stream_name)), consumer_group_size)
+//
+// Zero is returned if the size is less than one.
+func ConsumerGroupMemberFor(stream StreamIdentifier, size int64) int64 {
+	if size < 1 {
+		return 0
+	}
+
+	hash := Hash64(stream.CardinalID())
+
+	// take the absolute value as an unsigned integer so that the minimum
+	// int64 value does not overflow.
+	abs := uint64(hash)
+	if hash < 0 {
+		abs = uint64(-hash)
+	}
+
+	return int64(abs % uint64(size))
+}
@@ -0,0 +1,67 @@
+package gomdb
+
+import "testing"
+
+func Test_Hash64(t *testing.T) {
+	testcases := []struct {
+		value    string
+		expected int64
+	}{
+		{value: "", expected: -3162216497309240828},
+		{value: "123", expected: 2318431741638412123},
+		{value: "abc", expected: -8070080442485551184},
+		{value: "00000000-0000-0000-0000-000000000000", expected: -6950804328280008906},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.value, func(t *testing.T) {
+			if hash := Hash64(tc.value); hash != tc.expected {
+				t.Fatalf("expected %v, actual %v", tc.expected, hash)
+			}
+		})
+	}
+}
+
+func Test_ConsumerGroupMemberFor(t *testing.T) {
+	testcases := []struct {
+		name     string
+		stream   StreamIdentifier
+		size     int64
+		expected int64
+	}{
+		{
+			name:     "positive hash",
+			stream:   StreamIdentifier{Category: "account", ID: "123"},
+			size:     7,
+			expected: 3,
+		},
+		{
+			name:     "negative hash",
+			stream:   StreamIdentifier{Category: "account", ID: "abc"},
+			size:     3,
+			expected: 2,
+		},
+		{
+			name:     "compound ID uses cardinal ID",
+			stream:   StreamIdentifier{Category: "account:command", ID: "123+abc"},
+			size:     7,
+			expected: 3,
+		},
+		{
+			name:     "no consumer group",
+			stream:   StreamIdentifier{Category: "account", ID: "123"},
+			size:     0,
+			expected: 0,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if member := ConsumerGroupMemberFor(tc.stream, tc.size); member != tc.expected {
+				t.Fatalf("expected member %v, actual %v", tc.expected, member)
+			}
+		})
+	}
+}
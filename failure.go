@@ -0,0 +1,192 @@
+package gomdb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math"
+	"runtime/debug"
+	"time"
+)
+
+// MessageHandlerE handles messages as they appear after being written, and
+// returns an error if the message could not be handled. What happens to a
+// message that could not be handled is decided by the subscription's
+// FailurePolicy.
+type MessageHandlerE func(*Message) error
+
+// BatchHandler handles all of the messages read by a subscription's poll, for
+// example to project them within a single transaction. It returns an error if
+// the batch could not be handled, in which case each message is handled on
+// its own, as described by FailurePolicy.
+type BatchHandler func([]*Message) error
+
+// PanicError is the error a subscription reports when one of its handlers, or
+// its PollingStrategy, RestartPolicy or LagHandler, panics. Panics while
+// handling a message are passed to the FailurePolicy like any other handler
+// error, while other panics stop the subscription.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value interface{}
+	// Stack is the stack trace of the goroutine that panicked.
+	Stack []byte
+	// Message is the message being handled when the handler panicked, or nil
+	// if the panic did not happen while handling a single message.
+	Message *Message
+}
+
+func (e *PanicError) Error() string {
+	if e.Message != nil {
+		return fmt.Sprintf("panic handling message %s at %s/%v: %v", e.Message.ID, e.Message.Stream, e.Message.Version, e.Value)
+	}
+
+	return fmt.Sprintf("panic in subscription: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// recoverPanic recovers a panic into err as a *PanicError. It must be called
+// directly by a deferred statement.
+func recoverPanic(msg *Message, err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{
+			Value:   r,
+			Stack:   debug.Stack(),
+			Message: msg,
+		}
+	}
+}
+
+// FailurePolicy decides what a subscription does when its handler fails to
+// handle a message. Calling retry passes the message to the handler again.
+// Returning nil moves the subscription past the message, while returning an
+// error stops the subscription with that error. When a BatchHandler fails
+// each message of the batch is passed to it on its own, and the policy is
+// passed each message that still fails, with retry passing just that message
+// to the handler again.
+type FailurePolicy func(ctx context.Context, msg *Message, err error, retry func() error) error
+
+// StopOnFailure returns a FailurePolicy that stops the subscription when a
+// message fails to be handled. This is the default policy.
+func StopOnFailure() FailurePolicy {
+	return func(ctx context.Context, msg *Message, err error, retry func() error) error {
+		return fmt.Errorf("handling message %s at %s/%v: %w", msg.ID, msg.Stream, msg.Version, err)
+	}
+}
+
+// SkipOnFailure returns a FailurePolicy that ignores handler errors and moves
+// the subscription past the failed message.
+func SkipOnFailure() FailurePolicy {
+	return func(ctx context.Context, msg *Message, err error, retry func() error) error {
+		return nil
+	}
+}
+
+// RetryWithBackoff returns a FailurePolicy that retries a failed message up to
+// the specified number of attempts. The delay before each attempt starts at
+// the min duration and is multiplied after every attempt up to the max
+// duration. If every attempt fails then the fallback policy is applied.
+func RetryWithBackoff(attempts int, min, max time.Duration, multiplier float64, fallback FailurePolicy) FailurePolicy {
+	return func(ctx context.Context, msg *Message, err error, retry func() error) error {
+		for attempt := 0; attempt < attempts; attempt++ {
+			timer := time.NewTimer(backoff(attempt, min, max, multiplier))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+
+			if err = retry(); err == nil {
+				return nil
+			}
+		}
+
+		return fallback(ctx, msg, err, retry)
+	}
+}
+
+// backoff returns the delay before the specified attempt, counting from 0,
+// which starts at the min duration and is multiplied after every attempt up
+// to the max duration.
+func backoff(attempt int, min, max time.Duration, multiplier float64) time.Duration {
+	// the delay is capped before converting it, as it may overflow.
+	delay := math.Pow(multiplier, float64(attempt)) * float64(min)
+	if !(delay <= float64(max)) {
+		return max
+	} else if delay < 0 {
+		return 0
+	}
+
+	return time.Duration(delay)
+}
+
+// DeadLetterMetadata is the metadata of a message written to a dead-letter
+// stream by the DeadLetterOnFailure policy.
+type DeadLetterMetadata struct {
+	OriginalStreamName     string          `json:"originalStreamName"`
+	OriginalMessageID      string          `json:"originalMessageId"`
+	OriginalType           string          `json:"originalType"`
+	OriginalPosition       int64           `json:"originalPosition"`
+	OriginalGlobalPosition int64           `json:"originalGlobalPosition"`
+	OriginalMetadata       json.RawMessage `json:"originalMetadata,omitempty"`
+	Error                  string          `json:"error"`
+}
+
+// DeadLetterOnFailure returns a FailurePolicy that writes a failed message to
+// the dead-letter stream and moves the subscription past it. The dead-letter
+// message has the same type and data as the failed message, and a
+// DeadLetterMetadata describing where the original came from and why it
+// failed. If the dead-letter message cannot be written then the subscription
+// is stopped.
+func DeadLetterOnFailure(client *Client, deadLetter StreamIdentifier) FailurePolicy {
+	return func(ctx context.Context, msg *Message, err error, retry func() error) error {
+		id, idErr := newMessageID()
+		if idErr != nil {
+			return fmt.Errorf("generating dead-letter message ID: %w", idErr)
+		}
+
+		metadata := DeadLetterMetadata{
+			OriginalStreamName:     msg.Stream.String(),
+			OriginalMessageID:      msg.ID,
+			OriginalType:           msg.Type,
+			OriginalPosition:       msg.Version,
+			OriginalGlobalPosition: msg.GlobalPosition,
+			Error:                  err.Error(),
+		}
+
+		if len(msg.metadata) > 0 && json.Valid(msg.metadata) {
+			metadata.OriginalMetadata = msg.metadata
+		}
+
+		_, writeErr := client.WriteMessage(ctx, deadLetter, ProposedMessage{
+			ID:       id,
+			Type:     msg.Type,
+			Data:     json.RawMessage(msg.data),
+			Metadata: metadata,
+		}, AnyVersion)
+		if writeErr != nil {
+			return fmt.Errorf("writing message %s to dead-letter stream after %v: %w", msg.ID, err, writeErr)
+		}
+
+		return nil
+	}
+}
+
+// newMessageID returns a random (version 4) UUID.
+func newMessageID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
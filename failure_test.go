@@ -0,0 +1,130 @@
+package gomdb
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func Test_FailurePolicies(t *testing.T) {
+	t.Parallel()
+
+	errHandler := errors.New("handler failed")
+	errFallback := errors.New("fallback")
+
+	fallback := func(ctx context.Context, msg *Message, err error, retry func() error) error {
+		return errFallback
+	}
+
+	testcases := []struct {
+		name     string
+		policy   FailurePolicy
+		failures int
+		expCalls int
+		expErr   error
+	}{
+		{
+			name:     "stop on failure",
+			policy:   StopOnFailure(),
+			failures: 1,
+			expCalls: 0,
+			expErr:   errHandler,
+		},
+		{
+			name:     "skip on failure",
+			policy:   SkipOnFailure(),
+			failures: 1,
+			expCalls: 0,
+		},
+		{
+			name:     "retry succeeds",
+			policy:   RetryWithBackoff(3, time.Millisecond, 2*time.Millisecond, 2, fallback),
+			failures: 2,
+			expCalls: 2,
+		},
+		{
+			name:     "retries exhausted",
+			policy:   RetryWithBackoff(3, time.Millisecond, 2*time.Millisecond, 2, fallback),
+			failures: 10,
+			expCalls: 3,
+			expErr:   errFallback,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			calls := 0
+			retry := func() error {
+				calls++
+				if calls < tc.failures {
+					return errHandler
+				}
+
+				return nil
+			}
+
+			err := tc.policy(context.TODO(), &Message{ID: "someID"}, errHandler, retry)
+			if !errors.Is(err, tc.expErr) {
+				t.Fatalf("expected %v, actual %v", tc.expErr, err)
+			} else if calls != tc.expCalls {
+				t.Fatalf("expected %v retries, actual %v", tc.expCalls, calls)
+			}
+		})
+	}
+}
+
+func Test_RetryWithBackoff_cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	policy := RetryWithBackoff(3, time.Hour, time.Hour, 2, StopOnFailure())
+
+	err := policy(ctx, &Message{}, errors.New("handler failed"), func() error {
+		t.Fatal("message should not be retried")
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, actual %v", context.Canceled, err)
+	}
+}
+
+func Test_backoff(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name       string
+		attempt    int
+		multiplier float64
+		expected   time.Duration
+	}{
+		{name: "first attempt", attempt: 0, multiplier: 2, expected: 100 * time.Millisecond},
+		{name: "doubled", attempt: 2, multiplier: 2, expected: 400 * time.Millisecond},
+		{name: "fractional multiplier", attempt: 1, multiplier: 1.5, expected: 150 * time.Millisecond},
+		{name: "fractional multiplier squared", attempt: 2, multiplier: 1.5, expected: 225 * time.Millisecond},
+		{name: "multiplier below 1", attempt: 1, multiplier: 0.5, expected: 50 * time.Millisecond},
+		{name: "capped", attempt: 10, multiplier: 2, expected: time.Second},
+		{name: "overflow", attempt: 1000, multiplier: 2, expected: time.Second},
+	}
+
+	for _, tc := range testcases {
+		if actual := backoff(tc.attempt, 100*time.Millisecond, time.Second, tc.multiplier); actual != tc.expected {
+			t.Fatalf("%s: expected %v, actual %v", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func Test_newMessageID(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	id, err := newMessageID()
+	if err != nil {
+		t.Fatal(err)
+	} else if !pattern.MatchString(id) {
+		t.Fatalf("expected version 4 UUID, actual %s", id)
+	}
+}
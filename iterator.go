@@ -0,0 +1,111 @@
+package gomdb
+
+import "context"
+
+// Iterator pages through all of the messages in a stream or category, reading
+// one batch at a time so that memory use stays bounded. Use Next to advance
+// the iterator and Message to retrieve the current message. Once Next returns
+// false, Err reports any error that stopped the iteration.
+type Iterator struct {
+	ctx       context.Context
+	fetch     func(ctx context.Context) ([]*Message, error)
+	batchSize int64
+	batch     []*Message
+	idx       int
+	msg       *Message
+	last      bool
+	err       error
+}
+
+// IterateStream returns an Iterator over all of the messages in a stream. By
+// default the stream is read from the beginning in batches of 1000. Use
+// GetStreamOptions to adjust this behaviour.
+func (c *Client) IterateStream(ctx context.Context, stream StreamIdentifier, opts ...GetStreamOption) *Iterator {
+	cfg := newDefaultStreamConfig(c.defaultPollingStrat())
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return &Iterator{
+		ctx:       ctx,
+		batchSize: cfg.batchSize,
+		fetch: func(ctx context.Context) ([]*Message, error) {
+			msgs, err := c.GetStreamMessages(ctx, stream, func(c *streamConfig) { *c = *cfg })
+			if len(msgs) > 0 {
+				cfg.version = msgs[len(msgs)-1].Version + 1
+			}
+
+			return msgs, err
+		},
+	}
+}
+
+// IterateCategory returns an Iterator over all of the messages in a category.
+// By default the category is read from the beginning of the message store in
+// batches of 1000. Use GetCategoryOptions to adjust this behaviour and to
+// configure consumer groups and filtering.
+func (c *Client) IterateCategory(ctx context.Context, category string, opts ...GetCategoryOption) *Iterator {
+	cfg := newDefaultCategoryConfig(c.defaultPollingStrat())
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return &Iterator{
+		ctx:       ctx,
+		batchSize: cfg.batchSize,
+		fetch: func(ctx context.Context) ([]*Message, error) {
+			msgs, err := c.GetCategoryMessages(ctx, category, func(c *categoryConfig) { *c = *cfg })
+			if len(msgs) > 0 {
+				cfg.position = msgs[len(msgs)-1].GlobalPosition + 1
+			}
+
+			return msgs, err
+		},
+	}
+}
+
+// Next advances the iterator to the next message, reading the next batch of
+// messages when the current one is exhausted. It returns false when there are
+// no more messages or an error occurred.
+func (it *Iterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	if it.idx >= len(it.batch) {
+		// a short batch means the end has been reached.
+		if it.last {
+			it.msg = nil
+			return false
+		}
+
+		it.batch, it.err = it.fetch(it.ctx)
+		it.idx = 0
+		if it.err != nil {
+			it.batch, it.msg = nil, nil
+			return false
+		}
+
+		it.last = int64(len(it.batch)) < it.batchSize
+		if len(it.batch) == 0 {
+			it.msg = nil
+			return false
+		}
+	}
+
+	it.msg = it.batch[it.idx]
+	it.idx++
+
+	return true
+}
+
+// Message returns the current message, or nil if Next has not been called or
+// has returned false.
+func (it *Iterator) Message() *Message {
+	return it.msg
+}
+
+// Err returns the error, if any, that stopped the iteration.
+func (it *Iterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,94 @@
+package gomdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func Test_Iterator(t *testing.T) {
+	errFetch := errors.New("fetch failed")
+
+	testcases := []struct {
+		name      string
+		batchSize int64
+		batches   [][]*Message
+		fetchErr  error
+		expected  int
+		expFetch  int
+		expErr    error
+	}{
+		{
+			name:      "empty",
+			batchSize: 2,
+			batches:   [][]*Message{{}},
+			expected:  0,
+			expFetch:  1,
+		},
+		{
+			name:      "short final batch",
+			batchSize: 2,
+			batches:   [][]*Message{{{}, {}}, {{}}},
+			expected:  3,
+			expFetch:  2,
+		},
+		{
+			name:      "full final batch",
+			batchSize: 2,
+			batches:   [][]*Message{{{}, {}}, {{}, {}}, {}},
+			expected:  4,
+			expFetch:  3,
+		},
+		{
+			name:      "fetch error",
+			batchSize: 2,
+			batches:   [][]*Message{{{}, {}}},
+			fetchErr:  errFetch,
+			expected:  2,
+			expFetch:  2,
+			expErr:    errFetch,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			fetches := 0
+			it := &Iterator{
+				ctx:       context.TODO(),
+				batchSize: tc.batchSize,
+				fetch: func(context.Context) ([]*Message, error) {
+					fetches++
+					if fetches > len(tc.batches) {
+						return nil, tc.fetchErr
+					}
+
+					return tc.batches[fetches-1], nil
+				},
+			}
+
+			read := 0
+			for it.Next() {
+				if it.Message() == nil {
+					t.Fatal("expected message but got nil")
+				}
+				read++
+			}
+
+			// further calls must not fetch again.
+			if it.Next() {
+				t.Fatal("expected iterator to be exhausted")
+			}
+
+			if read != tc.expected {
+				t.Fatalf("expected %v messages, actual %v", tc.expected, read)
+			} else if fetches != tc.expFetch {
+				t.Fatalf("expected %v fetches, actual %v", tc.expFetch, fetches)
+			} else if !errors.Is(it.Err(), tc.expErr) {
+				t.Fatalf("expected %v, actual %v", tc.expErr, it.Err())
+			} else if it.Message() != nil {
+				t.Fatal("expected nil message after iteration")
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package gomdb
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Lag is how far a subscription is behind the stream or category it reads.
+type Lag struct {
+	// Head is the stream version or global position of the last message
+	// written to the stream or category, or -1 if it is empty. For consumer
+	// groups it is the head of the whole category, and for merged
+	// subscriptions it is the latest head of their categories.
+	Head int64
+	// Position is the stream version or global position of the last handled
+	// message, one before Subscription.Position.
+	Position int64
+	// Messages is the number of messages after Position that have not been
+	// handled. Consumer groups only count their own messages. Conditions and
+	// correlations are ignored.
+	Messages int64
+	// Time is how long ago the oldest unhandled message was written, according
+	// to its Timestamp, or 0 if the subscription has caught up.
+	Time time.Duration
+}
+
+// LagHandler is called periodically with the lag of a subscription, or with
+// the error that stopped the lag from being read. If it panics the
+// subscription stops with a *PanicError.
+type LagHandler func(Lag, error)
+
+// errNoLag is returned by subscriptions that cannot report their lag.
+var errNoLag = errors.New("subscription does not report lag")
+
+// lagQuery limits the work done to read a subscription's lag, when only part
+// of it is needed.
+type lagQuery struct {
+	// countLimit stops counting category messages once this many have been
+	// counted if it is greater than 0, and skips counting them if it is less
+	// than 0.
+	countLimit int64
+	// skipTime skips reading the oldest unhandled message.
+	skipTime bool
+}
+
+// Lag queries how far the subscription is behind the stream or category it
+// reads. It is safe to call while the subscription is running.
+func (s *Subscription) Lag(ctx context.Context) (Lag, error) {
+	if s.lag == nil {
+		return Lag{}, errNoLag
+	}
+
+	return s.lag(ctx, s.Position(), lagQuery{})
+}
+
+// streamLag returns the lag of a stream subscription that will next read the
+// specified version.
+func (c *Client) streamLag(ctx context.Context, stream StreamIdentifier, version int64, query lagQuery) (Lag, error) {
+	head, err := c.GetStreamVersion(ctx, stream)
+	if err != nil {
+		return Lag{}, err
+	}
+
+	lag := Lag{Head: head, Position: version - 1}
+	if head < version {
+		return lag, nil
+	}
+
+	lag.Messages = head - lag.Position
+	if query.skipTime {
+		return lag, nil
+	}
+
+	msgs, err := c.GetStreamMessages(ctx, stream, FromVersion(version), WithStreamBatchSize(1))
+	if err != nil {
+		return Lag{}, err
+	} else if len(msgs) > 0 {
+		lag.Time = timeSince(msgs[0].Timestamp)
+	}
+
+	return lag, nil
+}
+
+// categoryLag returns the lag of a category subscription that will next read
+// the specified global position, as a consumer group member if size is
+// greater than 0.
+func (c *Client) categoryLag(ctx context.Context, category string, position, member, size int64, query lagQuery) (Lag, error) {
+	head, err := c.GetCategoryHeadPosition(ctx, category)
+	if err != nil {
+		return Lag{}, err
+	}
+
+	lag := Lag{Head: head, Position: position - 1}
+	if head < position {
+		return lag, nil
+	}
+
+	if query.countLimit >= 0 {
+		if lag.Messages, err = c.countCategoryMessages(ctx, category, position, member, size, query.countLimit); err != nil {
+			return Lag{}, err
+		} else if lag.Messages == 0 {
+			return lag, nil
+		}
+	}
+
+	if query.skipTime {
+		return lag, nil
+	}
+
+	msgs, err := c.GetCategoryMessages(ctx, category, FromPosition(position), WithCategoryBatchSize(1), AsConsumerGroup(member, size))
+	if err != nil {
+		return Lag{}, err
+	} else if len(msgs) > 0 {
+		lag.Time = timeSince(msgs[0].Timestamp)
+	}
+
+	return lag, nil
+}
+
+// categoriesLag returns the combined lag of a merged subscription to several
+// categories: the latest head, the total number of unhandled messages and the
+// age of the oldest of them.
+func (c *Client) categoriesLag(ctx context.Context, categories []string, position, member, size int64, query lagQuery) (Lag, error) {
+	lag := Lag{Head: -1, Position: position - 1}
+
+	for _, category := range categories {
+		l, err := c.categoryLag(ctx, category, position, member, size, query)
+		if err != nil {
+			return Lag{}, err
+		}
+
+		if l.Head > lag.Head {
+			lag.Head = l.Head
+		}
+
+		lag.Messages += l.Messages
+
+		if l.Time > lag.Time {
+			lag.Time = l.Time
+		}
+	}
+
+	return lag, nil
+}
+
+// timeSince returns the time since t, or 0 if t is in the future because of
+// clock skew between the client and the database.
+func timeSince(t time.Time) time.Duration {
+	if d := time.Since(t); d > 0 {
+		return d
+	}
+
+	return 0
+}
+
+// reportLag calls the handler with the subscription's lag every interval
+// until the context is cancelled. If the handler panics then the *PanicError
+// is sent on failed, without waiting, so that the subscription can stop.
+func reportLag(ctx context.Context, sub *Subscription, handleLag LagHandler, interval time.Duration, failed chan<- error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		lag, err := sub.Lag(ctx)
+
+		// lag queries fail when the subscription is stopped.
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err := callLagHandler(handleLag, lag, err); err != nil {
+			select {
+			case failed <- err:
+			default:
+			}
+		}
+	}
+}
+
+// callLagHandler calls the handler, returning its panic as a *PanicError.
+func callLagHandler(handleLag LagHandler, lag Lag, lagErr error) (err error) {
+	defer recoverPanic(nil, &err)
+
+	handleLag(lag, lagErr)
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package gomdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_runSubscription_lagHandler(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	sub := newSubscription(cancel, 0)
+	sub.lag = func(ctx context.Context, position int64, query lagQuery) (Lag, error) {
+		return Lag{Head: 9, Position: position - 1, Messages: 10 - position}, nil
+	}
+
+	reported := make(chan Lag, 100)
+
+	loop := testLoop(testMessages(10), 4)
+	loop.pollingStrat = ConstantPolling(time.Millisecond)()
+	loop.handleMessage = func(msg *Message) error { return nil }
+	loop.handleLag = func(lag Lag, err error) {
+		if err != nil {
+			t.Error(err)
+		}
+		select {
+		case reported <- lag:
+		default:
+		}
+	}
+	loop.lagInterval = time.Millisecond
+
+	go (&Client{}).runSubscription(ctx, sub, loop)
+
+	// wait until the subscription is reported as caught up.
+	for lag := range reported {
+		if lag.Messages == 0 {
+			if lag.Position != 9 {
+				t.Fatalf("expected position 9, actual %v", lag.Position)
+			}
+			break
+		}
+	}
+
+	sub.Stop()
+	if err := sub.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	// lag is no longer reported once the subscription is done.
+	for len(reported) > 0 {
+		<-reported
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if len(reported) > 0 {
+		t.Fatal("expected no lag to be reported after the subscription stopped")
+	}
+}
+
+func Test_runSubscription_lagHandlerPanics(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	sub := newSubscription(cancel, 0)
+	sub.lag = func(ctx context.Context, position int64, query lagQuery) (Lag, error) {
+		return Lag{Head: -1, Position: position - 1}, nil
+	}
+
+	loop := testLoop(nil, 4)
+	loop.pollingStrat = ConstantPolling(time.Millisecond)()
+	loop.handleMessage = func(msg *Message) error { return nil }
+	loop.handleLag = func(Lag, error) {
+		panic("lag handler failed")
+	}
+	loop.lagInterval = time.Millisecond
+
+	go (&Client{}).runSubscription(ctx, sub, loop)
+
+	var panicErr *PanicError
+	if err := sub.Wait(); !errors.As(err, &panicErr) || panicErr.Value != "lag handler failed" {
+		t.Fatalf("expected lag handler panic, actual %v", err)
+	}
+}
+
+func Test_Subscription_Lag(t *testing.T) {
+	t.Parallel()
+
+	sub := newSubscription(func() {}, 0)
+	if _, err := sub.Lag(context.TODO()); !errors.Is(err, errNoLag) {
+		t.Fatalf("expected %v, actual %v", errNoLag, err)
+	}
+
+	sub.setPosition(5)
+	sub.lag = func(ctx context.Context, position int64, query lagQuery) (Lag, error) {
+		return Lag{Position: position - 1}, nil
+	}
+
+	if lag, err := sub.Lag(context.TODO()); err != nil {
+		t.Fatal(err)
+	} else if lag.Position != 4 {
+		t.Fatalf("expected position 4, actual %v", lag.Position)
+	}
+}
+
+func Test_timeSince(t *testing.T) {
+	t.Parallel()
+
+	if d := timeSince(time.Now().Add(-time.Minute)); d < time.Minute {
+		t.Fatalf("expected at least a minute, actual %v", d)
+	} else if d := timeSince(time.Now().Add(time.Minute)); d != 0 {
+		t.Fatalf("expected 0 for future timestamps, actual %v", d)
+	}
+}
@@ -0,0 +1,115 @@
+package gomdb
+
+import (
+	"context"
+	"time"
+)
+
+// LivenessRules configure when a subscription is reported as live. By
+// default a subscription goes live as soon as a poll reads fewer messages
+// than the batch size, and falls behind as soon as a poll reads a full batch.
+type LivenessRules struct {
+	// MaxLagMessages keeps a subscription live while it is at most this many
+	// messages behind, as reported by Subscription.Lag. Zero disables the
+	// threshold.
+	MaxLagMessages int64
+	// MaxLagTime keeps a subscription live while the oldest unhandled message
+	// was written at most this long ago, as reported by Subscription.Lag. Zero
+	// disables the threshold.
+	MaxLagTime time.Duration
+	// Polls is the number of consecutive polls that must disagree with the
+	// current liveness before it changes. Zero or one changes it on the first
+	// poll.
+	Polls int
+	// MinDwell is the minimum duration between liveness changes. A change
+	// that is due sooner is made on the first poll after MinDwell has passed,
+	// if the polls still agree. The first time a subscription goes live is not
+	// delayed.
+	MinDwell time.Duration
+}
+
+func (r LivenessRules) validate() error {
+	if r.MaxLagMessages < 0 || r.MaxLagTime < 0 || r.Polls < 0 || r.MinDwell < 0 {
+		return ErrInvalidLivenessRules
+	}
+
+	return nil
+}
+
+// withinThresholds returns whether the lag is within the configured lag
+// thresholds.
+func (r LivenessRules) withinThresholds(lag Lag) bool {
+	return (r.MaxLagMessages == 0 || lag.Messages <= r.MaxLagMessages) &&
+		(r.MaxLagTime == 0 || lag.Time <= r.MaxLagTime)
+}
+
+// hasThresholds returns whether liveness is decided by the subscription's
+// lag rather than by the size of the last poll.
+func (r LivenessRules) hasThresholds() bool {
+	return r.MaxLagMessages > 0 || r.MaxLagTime > 0
+}
+
+// lagQuery returns a query that reads only as much lag as the thresholds
+// need. Messages are only counted for a message threshold, and only until it
+// has been exceeded. The oldest message is only read for a time threshold.
+func (r LivenessRules) lagQuery() lagQuery {
+	query := lagQuery{countLimit: -1, skipTime: r.MaxLagTime == 0}
+	if r.MaxLagMessages > 0 {
+		query.countLimit = r.MaxLagMessages + 1
+	}
+
+	return query
+}
+
+// liveness applies LivenessRules to the outcome of each poll.
+type liveness struct {
+	rules LivenessRules
+
+	live bool
+	// disagreed is the number of consecutive polls that have disagreed with
+	// live.
+	disagreed int
+	// changed is when live last changed, and is zero until it first changes.
+	changed time.Time
+}
+
+// caughtUp returns whether a poll that read the specified number of messages
+// has caught up, reading the subscription's lag if the rules have lag
+// thresholds and the poll read a full batch.
+func (l *liveness) caughtUp(ctx context.Context, sub *Subscription, read, batchSize int64) (bool, error) {
+	if read < batchSize {
+		return true, nil
+	} else if !l.rules.hasThresholds() {
+		return false, nil
+	} else if sub.lag == nil {
+		return false, errNoLag
+	}
+
+	lag, err := sub.lag(ctx, sub.Position(), l.rules.lagQuery())
+	if err != nil {
+		return false, err
+	}
+
+	return l.rules.withinThresholds(lag), nil
+}
+
+// observe records whether a poll caught up, and returns whether the liveness
+// has changed as a result.
+func (l *liveness) observe(caughtUp bool, now time.Time) bool {
+	if caughtUp == l.live {
+		l.disagreed = 0
+		return false
+	}
+
+	if l.disagreed++; l.disagreed < l.rules.Polls {
+		return false
+	} else if !l.changed.IsZero() && now.Sub(l.changed) < l.rules.MinDwell {
+		return false
+	}
+
+	l.live = caughtUp
+	l.disagreed = 0
+	l.changed = now
+
+	return true
+}
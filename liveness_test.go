@@ -0,0 +1,190 @@
+package gomdb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_liveness_observe(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+
+	type poll struct {
+		caughtUp bool
+		after    time.Duration
+		expLive  bool
+	}
+
+	testcases := []struct {
+		name  string
+		rules LivenessRules
+		polls []poll
+	}{
+		{
+			name: "default rules change on every poll",
+			polls: []poll{
+				{caughtUp: false, expLive: false},
+				{caughtUp: true, expLive: true},
+				{caughtUp: false, expLive: false},
+				{caughtUp: true, expLive: true},
+			},
+		},
+		{
+			name:  "consecutive polls",
+			rules: LivenessRules{Polls: 2},
+			polls: []poll{
+				{caughtUp: true, expLive: false},
+				{caughtUp: true, expLive: true},
+				{caughtUp: false, expLive: true},
+				{caughtUp: true, expLive: true},
+				{caughtUp: false, expLive: true},
+				{caughtUp: false, expLive: false},
+			},
+		},
+		{
+			name:  "minimum dwell",
+			rules: LivenessRules{MinDwell: time.Minute},
+			polls: []poll{
+				{caughtUp: true, after: 0, expLive: true},
+				{caughtUp: false, after: time.Second, expLive: true},
+				{caughtUp: false, after: time.Minute, expLive: false},
+				{caughtUp: true, after: time.Minute + time.Second, expLive: false},
+				{caughtUp: true, after: 2 * time.Minute, expLive: true},
+			},
+		},
+		{
+			name:  "consecutive polls and minimum dwell",
+			rules: LivenessRules{Polls: 2, MinDwell: time.Minute},
+			polls: []poll{
+				{caughtUp: true, after: 0, expLive: false},
+				{caughtUp: true, after: 0, expLive: true},
+				{caughtUp: false, after: time.Minute, expLive: true},
+				{caughtUp: true, after: time.Minute, expLive: true},
+				{caughtUp: false, after: 2 * time.Minute, expLive: true},
+				{caughtUp: false, after: 2 * time.Minute, expLive: false},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			l := liveness{rules: tc.rules}
+			for i, p := range tc.polls {
+				before := l.live
+				changed := l.observe(p.caughtUp, start.Add(p.after))
+
+				if l.live != p.expLive {
+					t.Fatalf("poll %d: expected live %v, actual %v", i, p.expLive, l.live)
+				} else if changed != (before != l.live) {
+					t.Fatalf("poll %d: expected changed %v, actual %v", i, before != l.live, changed)
+				}
+			}
+		})
+	}
+}
+
+func Test_liveness_caughtUp(t *testing.T) {
+	t.Parallel()
+
+	sub := newSubscription(func() {}, 0)
+	sub.lag = func(ctx context.Context, position int64, query lagQuery) (Lag, error) {
+		return Lag{Messages: 50, Time: time.Second}, nil
+	}
+
+	testcases := []struct {
+		name        string
+		rules       LivenessRules
+		read        int64
+		expCaughtUp bool
+	}{
+		{
+			name:        "short poll",
+			read:        5,
+			expCaughtUp: true,
+		},
+		{
+			name: "full poll",
+			read: 10,
+		},
+		{
+			name:        "within message threshold",
+			rules:       LivenessRules{MaxLagMessages: 50},
+			read:        10,
+			expCaughtUp: true,
+		},
+		{
+			name:  "outside message threshold",
+			rules: LivenessRules{MaxLagMessages: 49},
+			read:  10,
+		},
+		{
+			name:        "within time threshold",
+			rules:       LivenessRules{MaxLagTime: time.Second},
+			read:        10,
+			expCaughtUp: true,
+		},
+		{
+			name:  "outside time threshold",
+			rules: LivenessRules{MaxLagMessages: 100, MaxLagTime: time.Millisecond},
+			read:  10,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			l := liveness{rules: tc.rules}
+
+			caughtUp, err := l.caughtUp(context.TODO(), sub, tc.read, 10)
+			if err != nil {
+				t.Fatal(err)
+			} else if caughtUp != tc.expCaughtUp {
+				t.Fatalf("expected caught up %v, actual %v", tc.expCaughtUp, caughtUp)
+			}
+		})
+	}
+}
+
+func Test_LivenessRules_lagQuery(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name     string
+		rules    LivenessRules
+		expQuery lagQuery
+	}{
+		{
+			name:     "message threshold",
+			rules:    LivenessRules{MaxLagMessages: 50},
+			expQuery: lagQuery{countLimit: 51, skipTime: true},
+		},
+		{
+			name:     "time threshold",
+			rules:    LivenessRules{MaxLagTime: time.Second},
+			expQuery: lagQuery{countLimit: -1},
+		},
+		{
+			name:     "both thresholds",
+			rules:    LivenessRules{MaxLagMessages: 50, MaxLagTime: time.Second},
+			expQuery: lagQuery{countLimit: 51},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if query := tc.rules.lagQuery(); query != tc.expQuery {
+				t.Fatalf("expected %+v, actual %+v", tc.expQuery, query)
+			}
+		})
+	}
+}
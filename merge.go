@@ -0,0 +1,173 @@
+package gomdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrMissingCategories is returned when subscribing to an empty list of
+// categories.
+var ErrMissingCategories = errors.New("at least one category must be provided")
+
+// SubscribeToCategories subscribes to several categories at once, passing
+// their messages to the message handler in global position order. Each poll
+// reads every category with GetCategoryMessages and merges the results, so
+// the subscription has a single position: the next global position to read.
+// Category options apply to every category, so with AsConsumerGroup each
+// member receives the streams whose cardinal ID is assigned to it in all of
+// the categories, and with WithConsumerID the position is recorded in the
+// position stream of the first category.
+// Handlers, liveness and restarts behave as they do for SubscribeToCategory.
+func (c *Client) SubscribeToCategories(
+	ctx context.Context,
+	categories []string,
+	handleMessage MessageHandler,
+	handleLiveness LivenessHandler,
+	handleDropped SubDroppedHandler,
+	opts ...GetCategoryOption,
+) (*Subscription, error) {
+	cfg := newDefaultCategoryConfig(c.defaultPollingStrat())
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	// validate inputs
+	if err := validateCategories(categories); err != nil {
+		return nil, fmt.Errorf("validating categories: %w", err)
+	} else if (handleMessage == nil && cfg.handleMessageE == nil && cfg.handleBatch == nil) || handleLiveness == nil || handleDropped == nil {
+		return nil, errors.New("all subscription handlers are required")
+	} else if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("validating options: %w", err)
+	}
+
+	categories = append([]string(nil), categories...)
+
+	// durable consumers record their position in a position stream.
+	if cfg.positionStore == nil && cfg.consumerID != "" {
+		cfg.positionStore = NewMessageDBPositionStore(c, PositionStream(categories[0], cfg.consumerID))
+	}
+
+	tracker, recorded, err := cfg.positionTracker(ctx)
+	if err != nil {
+		return nil, err
+	} else if recorded >= 0 {
+		cfg.position = recorded + 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	sub := newSubscription(cancel, cfg.position)
+	member, size := cfg.consumerGroupMember, cfg.consumerGroupSize
+	sub.lag = func(ctx context.Context, position int64, query lagQuery) (Lag, error) {
+		return c.categoriesLag(ctx, categories, position, member, size, query)
+	}
+
+	go c.runSubscription(ctx, sub, subscriptionLoop{
+		batchSize:    cfg.batchSize,
+		pollingStrat: cfg.pollingStrat,
+		stream:       StreamIdentifier{Category: strings.Join(categories, ",")},
+		categories:   categories,
+		tracker:      tracker,
+		workers:      cfg.workers,
+		poll: func(ctx context.Context) ([]*Message, error) {
+			return c.getMergedCategoryMessages(ctx, categories, cfg)
+		},
+		advance: func(msg *Message) int64 {
+			cfg.position = msg.GlobalPosition + 1
+			return cfg.position
+		},
+		seek: func(position int64) {
+			cfg.position = position
+		},
+		handleBatch:    cfg.handleBatch,
+		batchLatency:   cfg.batchLatency,
+		handleMessage:  cfg.handler(handleMessage),
+		failurePolicy:  cfg.getFailurePolicy(),
+		handleLiveness: handleLiveness,
+		handleDropped:  handleDropped,
+		restartPolicy:  cfg.restartPolicy,
+		handleRestart:  cfg.handleRestart,
+		handleLag:      cfg.handleLag,
+		lagInterval:    cfg.lagInterval,
+		liveness:       liveness{rules: cfg.livenessRules},
+	})
+
+	return sub, nil
+}
+
+// validateCategories checks that there is at least one category, and that
+// each is valid and appears only once.
+func validateCategories(categories []string) error {
+	if len(categories) == 0 {
+		return ErrMissingCategories
+	}
+
+	seen := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		if err := validateCategory(category); err != nil {
+			return err
+		} else if seen[category] {
+			return fmt.Errorf("duplicate category %q", category)
+		}
+
+		seen[category] = true
+	}
+
+	return nil
+}
+
+// getMergedCategoryMessages reads a batch from each category and merges them
+// in global position order.
+func (c *Client) getMergedCategoryMessages(ctx context.Context, categories []string, cfg *categoryConfig) ([]*Message, error) {
+	// the head is read first, so that messages written to a category after it
+	// has been read cannot be skipped by messages read from a later one.
+	head, err := c.GetHeadPosition(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reads := make([][]*Message, len(categories))
+	for i, category := range categories {
+		reads[i], err = c.GetCategoryMessages(ctx, category, func(c *categoryConfig) { *c = *cfg })
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return mergeCategoryMessages(reads, head, cfg.batchSize), nil
+}
+
+// mergeCategoryMessages merges batches read from several categories into a
+// single batch in global position order. Only messages up to the head
+// position, and up to the end of every full batch, are merged, as any later
+// messages in the categories that were read may not have been read yet. At
+// most batchSize messages are returned.
+func mergeCategoryMessages(reads [][]*Message, head, batchSize int64) []*Message {
+	var merged []*Message
+
+	until := head
+	for _, msgs := range reads {
+		if int64(len(msgs)) >= batchSize {
+			if last := msgs[len(msgs)-1].GlobalPosition; last < until {
+				until = last
+			}
+		}
+
+		merged = append(merged, msgs...)
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].GlobalPosition < merged[j].GlobalPosition
+	})
+
+	n := sort.Search(len(merged), func(i int) bool {
+		return merged[i].GlobalPosition > until
+	})
+	if int64(n) > batchSize {
+		n = int(batchSize)
+	}
+
+	return merged[:n]
+}
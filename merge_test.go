@@ -0,0 +1,93 @@
+package gomdb
+
+import (
+	"errors"
+	"testing"
+)
+
+// testPositions returns messages at the global positions.
+func testPositions(positions ...int64) []*Message {
+	msgs := make([]*Message, len(positions))
+	for i, position := range positions {
+		msgs[i] = &Message{ID: "someID", GlobalPosition: position}
+	}
+
+	return msgs
+}
+
+func Test_mergeCategoryMessages(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name         string
+		reads        [][]*Message
+		head         int64
+		batchSize    int64
+		expPositions []int64
+	}{
+		{
+			name:         "short batches",
+			reads:        [][]*Message{testPositions(1, 4), testPositions(2, 3, 5)},
+			head:         5,
+			batchSize:    10,
+			expPositions: []int64{1, 2, 3, 4, 5},
+		},
+		{
+			name:         "full batch",
+			reads:        [][]*Message{testPositions(1, 4, 7), testPositions(2, 3, 9)},
+			head:         10,
+			batchSize:    3,
+			expPositions: []int64{1, 2, 3},
+		},
+		{
+			name:         "until the end of a full batch",
+			reads:        [][]*Message{testPositions(1, 2, 3), testPositions(5, 6)},
+			head:         10,
+			batchSize:    3,
+			expPositions: []int64{1, 2, 3},
+		},
+		{
+			name:         "until the head",
+			reads:        [][]*Message{testPositions(1, 3), testPositions(2, 8)},
+			head:         5,
+			batchSize:    10,
+			expPositions: []int64{1, 2, 3},
+		},
+		{
+			name:      "empty",
+			reads:     [][]*Message{nil, nil},
+			head:      -1,
+			batchSize: 10,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			merged := mergeCategoryMessages(tc.reads, tc.head, tc.batchSize)
+			if len(merged) != len(tc.expPositions) {
+				t.Fatalf("expected %v messages, actual %v", len(tc.expPositions), len(merged))
+			}
+
+			for i, msg := range merged {
+				if msg.GlobalPosition != tc.expPositions[i] {
+					t.Fatalf("message %d: expected position %v, actual %v", i, tc.expPositions[i], msg.GlobalPosition)
+				}
+			}
+		})
+	}
+}
+
+func Test_validateCategories(t *testing.T) {
+	t.Parallel()
+
+	if err := validateCategories(nil); !errors.Is(err, ErrMissingCategories) {
+		t.Fatalf("expected %v, actual %v", ErrMissingCategories, err)
+	} else if err := validateCategories([]string{"order", "order"}); err == nil {
+		t.Fatal("expected error for duplicate categories")
+	} else if err := validateCategories([]string{"order", "payment"}); err != nil {
+		t.Fatal(err)
+	}
+}
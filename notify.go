@@ -0,0 +1,205 @@
+package gomdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultNotifyChannel is the default channel that the notify trigger
+	// sends notifications on.
+	DefaultNotifyChannel = "gomdb_messages"
+	// DropNotifyTriggerSQL removes the notify trigger and its function.
+	DropNotifyTriggerSQL = `
+DROP TRIGGER IF EXISTS gomdb_notify_category ON message_store.messages;
+DROP FUNCTION IF EXISTS message_store.gomdb_notify_category();
+`
+	notifyTriggerSQL = `
+CREATE OR REPLACE FUNCTION message_store.gomdb_notify_category()
+RETURNS trigger
+AS $$
+BEGIN
+  PERFORM pg_notify(TG_ARGV[0], message_store.category(NEW.stream_name));
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS gomdb_notify_category ON message_store.messages;
+
+CREATE TRIGGER gomdb_notify_category
+AFTER INSERT ON message_store.messages
+FOR EACH ROW EXECUTE PROCEDURE message_store.gomdb_notify_category('%s');
+`
+	// notifierRetryDelay is how long to wait before listening again after a
+	// Notifier fails.
+	notifierRetryDelay = time.Second
+)
+
+// ErrInvalidNotifyChannel is returned when a notify channel name is not a
+// lower case Postgres identifier.
+var ErrInvalidNotifyChannel = errors.New("notify channel must be a lower case identifier of at most 63 characters")
+
+var notifyChannelRegexp = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// NotifyTriggerSQL returns the SQL that installs a trigger on
+// message_store.messages, which sends the category of every written message
+// as a notification on the channel.
+func NotifyTriggerSQL(channel string) (string, error) {
+	if !notifyChannelRegexp.MatchString(channel) {
+		return "", ErrInvalidNotifyChannel
+	}
+
+	return fmt.Sprintf(notifyTriggerSQL, channel), nil
+}
+
+// InstallNotifyTrigger installs, or replaces, the notify trigger so that a
+// category is sent as a notification on the channel whenever a message is
+// written to it.
+func InstallNotifyTrigger(ctx context.Context, db DB, channel string) error {
+	query, err := NotifyTriggerSQL(channel)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("installing notify trigger: %w", err)
+	}
+
+	return nil
+}
+
+// UninstallNotifyTrigger removes the notify trigger.
+func UninstallNotifyTrigger(ctx context.Context, db DB) error {
+	if _, err := db.ExecContext(ctx, DropNotifyTriggerSQL); err != nil {
+		return fmt.Errorf("uninstalling notify trigger: %w", err)
+	}
+
+	return nil
+}
+
+// Notifier listens for notifications sent by the notify trigger. pgxmdb
+// provides one using pgx. There is no implementation for database/sql.
+type Notifier interface {
+	// Listen calls notify with the category of every notification received
+	// until the context is cancelled or listening fails.
+	Listen(ctx context.Context, notify func(category string)) error
+}
+
+// notificationHub runs a Notifier while there are subscriptions, and wakes the
+// subscriptions to the notified categories.
+type notificationHub struct {
+	notifier Notifier
+
+	mu     sync.Mutex
+	subs   map[string]map[chan struct{}]struct{}
+	count  int
+	cancel context.CancelFunc
+}
+
+func newNotificationHub(notifier Notifier) *notificationHub {
+	return &notificationHub{
+		notifier: notifier,
+		subs:     map[string]map[chan struct{}]struct{}{},
+	}
+}
+
+// subscribe returns a channel that receives a value when a message is written
+// to any of the categories, and a function that unsubscribes. The channel is
+// nil if the hub is nil, so that it never receives.
+func (h *notificationHub) subscribe(categories ...string) (<-chan struct{}, func()) {
+	if h == nil {
+		return nil, func() {}
+	}
+
+	wake := make(chan struct{}, 1)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, category := range categories {
+		if h.subs[category] == nil {
+			h.subs[category] = map[chan struct{}]struct{}{}
+		}
+		h.subs[category][wake] = struct{}{}
+	}
+
+	// start listening with the first subscription.
+	if h.count++; h.cancel == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		h.cancel = cancel
+		go h.listen(ctx)
+	}
+
+	var once sync.Once
+
+	return wake, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+
+			for _, category := range categories {
+				delete(h.subs[category], wake)
+				if len(h.subs[category]) == 0 {
+					delete(h.subs, category)
+				}
+			}
+
+			// stop listening with the last subscription.
+			if h.count--; h.count == 0 {
+				h.cancel()
+				h.cancel = nil
+			}
+		})
+	}
+}
+
+// listen runs the Notifier until the context is cancelled, listening again
+// if it fails. Subscriptions keep polling while the Notifier is down, so
+// failures are not reported.
+func (h *notificationHub) listen(ctx context.Context) {
+	for {
+		_ = h.notifier.Listen(ctx, h.notify)
+
+		timer := time.NewTimer(notifierRetryDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// notifications may have been missed while the notifier was down.
+		h.notifyAll()
+	}
+}
+
+// notify wakes the subscriptions to the category without blocking.
+func (h *notificationHub) notify(category string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for wake := range h.subs[category] {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (h *notificationHub) notifyAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, subs := range h.subs {
+		for wake := range subs {
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
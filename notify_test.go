@@ -0,0 +1,160 @@
+package gomdb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNotifier passes categories sent on its channel to the hub.
+type fakeNotifier struct {
+	categories chan string
+}
+
+func (n *fakeNotifier) Listen(ctx context.Context, notify func(category string)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case category := <-n.categories:
+			notify(category)
+		}
+	}
+}
+
+func Test_NotifyTriggerSQL(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		channel string
+		expErr  error
+	}{
+		{channel: DefaultNotifyChannel},
+		{channel: "Messages", expErr: ErrInvalidNotifyChannel},
+		{channel: "messages'; DROP TABLE messages; --", expErr: ErrInvalidNotifyChannel},
+		{channel: "", expErr: ErrInvalidNotifyChannel},
+	}
+
+	for _, tc := range testcases {
+		if _, err := NotifyTriggerSQL(tc.channel); !errors.Is(err, tc.expErr) {
+			t.Fatalf("channel %q: expected %v, actual %v", tc.channel, tc.expErr, err)
+		}
+	}
+}
+
+func Test_notificationHub(t *testing.T) {
+	t.Parallel()
+
+	notifier := &fakeNotifier{categories: make(chan string)}
+	hub := newNotificationHub(notifier)
+
+	account, unsubscribeAccount := hub.subscribe("account")
+	user, unsubscribeUser := hub.subscribe("user")
+	both, unsubscribeBoth := hub.subscribe("account", "user")
+
+	notifier.categories <- "account"
+
+	select {
+	case <-account:
+	case <-time.After(time.Second):
+		t.Fatal("expected account subscription to be woken")
+	}
+
+	select {
+	case <-both:
+	case <-time.After(time.Second):
+		t.Fatal("expected subscription to both categories to be woken")
+	}
+
+	select {
+	case <-user:
+		t.Fatal("expected user subscription not to be woken")
+	default:
+	}
+
+	notifier.categories <- "user"
+
+	select {
+	case <-both:
+	case <-time.After(time.Second):
+		t.Fatal("expected subscription to both categories to be woken")
+	}
+
+	unsubscribeAccount()
+	unsubscribeUser()
+	unsubscribeBoth()
+	unsubscribeBoth()
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if hub.cancel != nil || len(hub.subs) != 0 {
+		t.Fatal("expected hub to stop listening after the last subscription")
+	}
+}
+
+func Test_runSubscription_notifications(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		msgs     []*Message
+		position int64
+	)
+
+	notifier := &fakeNotifier{categories: make(chan string)}
+	client := &Client{notifications: newNotificationHub(notifier)}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	sub := newSubscription(cancel, 0)
+	received := make(chan *Message)
+	goneLive := make(chan struct{})
+
+	go client.runSubscription(ctx, sub, subscriptionLoop{
+		batchSize: 10,
+		// only notifications will cause the subscription to poll again.
+		pollingStrat: ConstantPolling(time.Hour)(),
+		stream:       StreamIdentifier{Category: "account"},
+		poll: func(ctx context.Context) ([]*Message, error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return msgs[position:], nil
+		},
+		advance: func(msg *Message) int64 {
+			mu.Lock()
+			defer mu.Unlock()
+
+			position = msg.Version + 1
+			return position
+		},
+		handleMessage: func(msg *Message) error {
+			received <- msg
+			return nil
+		},
+		failurePolicy: StopOnFailure(),
+		handleLiveness: func(live bool) {
+			if live {
+				close(goneLive)
+			}
+		},
+		handleDropped: func(error) {},
+	})
+	defer sub.Stop()
+
+	<-goneLive
+
+	mu.Lock()
+	msgs = append(msgs, &Message{ID: "someID", Version: 0})
+	mu.Unlock()
+
+	notifier.categories <- "account"
+
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("expected notification to wake the subscription")
+	}
+}
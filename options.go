@@ -13,6 +13,9 @@ var (
 	// ErrInvalidReadBatchSize is returned when the batch size inside a read
 	// call is less than one.
 	ErrInvalidReadBatchSize = errors.New("batch size must be greater than 0")
+	// ErrInvalidReadMessageLimit is returned when the message limit inside a
+	// read call is less than zero.
+	ErrInvalidReadMessageLimit = errors.New("message limit cannot be less than 0 (0 for no limit)")
 	// ErrInvalidReadPosition is returned when the stream position inside a
 	// read call is less than zero.
 	ErrInvalidReadPosition = errors.New("stream position cannot be less than 0")
@@ -23,6 +26,27 @@ var (
 	// ErrInvalidConsumerGroupSize is returned when the consumer group size is
 	// less that zero.
 	ErrInvalidConsumerGroupSize = errors.New("consumer group size must be 0 or greater (0 to disbale consumer groups)")
+	// ErrInvalidPositionUpdateInterval is returned when the position update
+	// message count or interval is less than zero.
+	ErrInvalidPositionUpdateInterval = errors.New("position update interval cannot be less than 0 (0 to disable)")
+	// ErrInvalidChannelBuffer is returned when the channel buffer size is less
+	// than zero.
+	ErrInvalidChannelBuffer = errors.New("channel buffer cannot be less than 0")
+	// ErrInvalidWorkers is returned when the number of subscription workers
+	// is less than zero.
+	ErrInvalidWorkers = errors.New("workers cannot be less than 0")
+	// ErrInvalidBatchLatency is returned when the maximum batch latency is
+	// less than zero.
+	ErrInvalidBatchLatency = errors.New("batch latency cannot be less than 0")
+	// ErrBatchHandlerWorkers is returned when a subscription is configured
+	// with both a batch handler and workers.
+	ErrBatchHandlerWorkers = errors.New("batch handlers cannot be used with workers")
+	// ErrInvalidLagInterval is returned when a lag handler is configured
+	// without a positive reporting interval.
+	ErrInvalidLagInterval = errors.New("lag interval must be greater than 0")
+	// ErrInvalidLivenessRules is returned when any of the liveness rules are
+	// less than zero.
+	ErrInvalidLivenessRules = errors.New("liveness rules cannot be less than 0")
 )
 
 // ClientOption is an option for modifiying how the Message DB client operates.
@@ -36,6 +60,22 @@ func WithDefaultPollingStrategy(strat func() PollingStrategy) ClientOption {
 	}
 }
 
+// WithNotifier configures subscriptions to be woken by the Notifier as soon as
+// a message is written to their category, instead of waiting for their next
+// poll. Subscriptions still poll using their PollingStrategy in case
+// notifications are missed, so a longer polling interval can be used. The
+// notify trigger must be installed with InstallNotifyTrigger.
+//
+// Only pgxmdb provides a Notifier. The database/sql interface cannot listen
+// for notifications, so clients using database/sql drivers such as lib/pq
+// have none, and must implement Notifier with their driver's listener to use
+// this option.
+func WithNotifier(notifier Notifier) ClientOption {
+	return func(c *Client) {
+		c.notifications = newNotificationHub(notifier)
+	}
+}
+
 // PollingStrategy returns the delay duration before the next polling attempt
 // based on how many messages were returned from the previous poll vs how many
 // were expected.
@@ -157,11 +197,112 @@ func WithStreamPollingStrategy(strat PollingStrategy) GetStreamOption {
 	}
 }
 
+// WithStreamMessageLimit sets the maximum number of messages that may be read
+// from the stream. Message limits are only used by ReadAllStreamMessages, and
+// a limit of 0 means no limit.
+func WithStreamMessageLimit(limit int64) GetStreamOption {
+	return func(cfg *streamConfig) {
+		cfg.messageLimit = limit
+	}
+}
+
+// WithStreamMessageHandlerE sets an error returning message handler for this
+// stream subscription, which is used in place of the MessageHandler. Handler
+// errors are passed to the subscription's FailurePolicy.
+func WithStreamMessageHandlerE(handler MessageHandlerE) GetStreamOption {
+	return func(cfg *streamConfig) {
+		cfg.handleMessageE = handler
+	}
+}
+
+// WithStreamFailurePolicy sets the policy applied when the stream
+// subscription fails to handle a message. By default the subscription is
+// stopped.
+func WithStreamFailurePolicy(policy FailurePolicy) GetStreamOption {
+	return func(cfg *streamConfig) {
+		cfg.failurePolicy = policy
+	}
+}
+
+// WithStreamPositionStore sets the store used to record the position of this
+// stream subscription. The subscription resumes after the version read from
+// the store when it starts, ignoring FromVersion if a version has been
+// recorded.
+func WithStreamPositionStore(store PositionStore) GetStreamOption {
+	return func(cfg *streamConfig) {
+		cfg.positionStore = store
+	}
+}
+
+// WithStreamPositionUpdateInterval sets how often a stream subscription
+// records its position: after the specified number of handled messages, or
+// once the interval has elapsed since the last update, whichever comes first.
+// Zero disables either trigger. Defaults to every 100 messages or 5 seconds.
+func WithStreamPositionUpdateInterval(messages int64, interval time.Duration) GetStreamOption {
+	return func(cfg *streamConfig) {
+		cfg.positionUpdateMessages = messages
+		cfg.positionUpdateInterval = interval
+	}
+}
+
+// WithStreamChannelBuffer sets the number of messages buffered by a channel
+// stream subscription. Channel buffers are only used by SubscribeToStreamChan.
+func WithStreamChannelBuffer(buffer int64) GetStreamOption {
+	return func(cfg *streamConfig) {
+		cfg.channelBuffer = buffer
+	}
+}
+
+// WithStreamBatchHandler sets a handler that is passed every batch of
+// messages read by this stream subscription, which is used in place of the
+// MessageHandler. If maxLatency is greater than 0 then batches smaller than
+// the batch size are held, and messages from later polls added to them, for
+// up to maxLatency before they are handled. Failed batches are passed to the
+// subscription's FailurePolicy.
+func WithStreamBatchHandler(handler BatchHandler, maxLatency time.Duration) GetStreamOption {
+	return func(cfg *streamConfig) {
+		cfg.handleBatch = handler
+		cfg.batchLatency = maxLatency
+	}
+}
+
+// WithStreamRestartPolicy supervises this stream subscription, restarting it
+// from the last handled version when it fails and the policy allows it. The
+// optional handleRestart is called before each restart. Without a restart
+// policy failed subscriptions are dropped.
+func WithStreamRestartPolicy(policy RestartPolicy, handleRestart RestartHandler) GetStreamOption {
+	return func(cfg *streamConfig) {
+		cfg.restartPolicy = policy
+		cfg.handleRestart = handleRestart
+	}
+}
+
+// WithStreamLagHandler calls the handler with the lag of this stream
+// subscription every interval, on its own goroutine. Lag handlers are only
+// used in subscriptions.
+func WithStreamLagHandler(handler LagHandler, interval time.Duration) GetStreamOption {
+	return func(cfg *streamConfig) {
+		cfg.handleLag = handler
+		cfg.lagInterval = interval
+	}
+}
+
+// WithStreamLivenessRules sets the rules that decide when this stream
+// subscription is reported as live, so that bursts of messages do not make
+// the liveness flap.
+func WithStreamLivenessRules(rules LivenessRules) GetStreamOption {
+	return func(cfg *streamConfig) {
+		cfg.livenessRules = rules
+	}
+}
+
 type streamConfig struct {
+	subscriptionConfig
 	version      int64
 	batchSize    int64
 	condition    string
 	pollingStrat PollingStrategy
+	messageLimit int64
 }
 
 func (cfg *streamConfig) validate() error {
@@ -169,9 +310,11 @@ func (cfg *streamConfig) validate() error {
 		return ErrInvalidReadStreamVersion
 	} else if cfg.batchSize < 1 {
 		return ErrInvalidReadBatchSize
+	} else if cfg.messageLimit < 0 {
+		return ErrInvalidReadMessageLimit
 	}
 
-	return nil
+	return cfg.subscriptionConfig.validate()
 }
 
 func (cfg *streamConfig) getCondition() interface{} {
@@ -184,9 +327,10 @@ func (cfg *streamConfig) getCondition() interface{} {
 
 func newDefaultStreamConfig(strat PollingStrategy) *streamConfig {
 	return &streamConfig{
-		version:      0,
-		batchSize:    1000,
-		pollingStrat: strat,
+		subscriptionConfig: newDefaultSubscriptionConfig(),
+		version:            0,
+		batchSize:          1000,
+		pollingStrat:       strat,
 	}
 }
 
@@ -245,7 +389,125 @@ func WithCategoryPollingStrategy(strat PollingStrategy) GetCategoryOption {
 	}
 }
 
+// WithCategoryMessageHandlerE sets an error returning message handler for
+// this category subscription, which is used in place of the MessageHandler.
+// Handler errors are passed to the subscription's FailurePolicy.
+func WithCategoryMessageHandlerE(handler MessageHandlerE) GetCategoryOption {
+	return func(cfg *categoryConfig) {
+		cfg.handleMessageE = handler
+	}
+}
+
+// WithCategoryFailurePolicy sets the policy applied when the category
+// subscription fails to handle a message. By default the subscription is
+// stopped.
+func WithCategoryFailurePolicy(policy FailurePolicy) GetCategoryOption {
+	return func(cfg *categoryConfig) {
+		cfg.failurePolicy = policy
+	}
+}
+
+// WithConsumerID makes the category subscription a durable consumer that
+// records its position in the stream "category:position-consumerId" using a
+// MessageDBPositionStore. When the
+// subscription starts it resumes after the last recorded position, ignoring
+// FromPosition if a position has been recorded. When reading as a consumer
+// group each member should use its own consumer ID. Consumer IDs are only used
+// in subscriptions.
+func WithConsumerID(consumerID string) GetCategoryOption {
+	return func(cfg *categoryConfig) {
+		cfg.consumerID = consumerID
+	}
+}
+
+// WithCategoryPositionStore sets the store used to record the position of
+// this category subscription. The subscription resumes after the position
+// read from the store when it starts, ignoring FromPosition if a position has
+// been recorded. It takes precedence over WithConsumerID.
+func WithCategoryPositionStore(store PositionStore) GetCategoryOption {
+	return func(cfg *categoryConfig) {
+		cfg.positionStore = store
+	}
+}
+
+// WithCategoryPositionUpdateInterval sets how often a category subscription
+// records its position: after the specified number of handled messages, or
+// once the interval has elapsed since the last update, whichever comes first.
+// Zero disables either trigger. Defaults to every 100 messages or 5 seconds.
+func WithCategoryPositionUpdateInterval(messages int64, interval time.Duration) GetCategoryOption {
+	return func(cfg *categoryConfig) {
+		cfg.positionUpdateMessages = messages
+		cfg.positionUpdateInterval = interval
+	}
+}
+
+// WithCategoryChannelBuffer sets the number of messages buffered by a channel
+// category subscription. Channel buffers are only used by
+// SubscribeToCategoryChan.
+func WithCategoryChannelBuffer(buffer int64) GetCategoryOption {
+	return func(cfg *categoryConfig) {
+		cfg.channelBuffer = buffer
+	}
+}
+
+// WithCategoryWorkers sets the number of goroutines that handle messages for
+// this category subscription. Messages are assigned to workers by the
+// cardinal ID of their stream, so messages within a stream are still handled
+// in order, and the handlers must be safe for concurrent use. The subscription
+// position only moves past a message once it and every message before it have
+// been handled. Workers are only used in subscriptions, and 0 or 1 handles
+// messages serially.
+func WithCategoryWorkers(workers int64) GetCategoryOption {
+	return func(cfg *categoryConfig) {
+		cfg.workers = workers
+	}
+}
+
+// WithCategoryBatchHandler sets a handler that is passed every batch of
+// messages read by this category subscription, which is used in place of the
+// MessageHandler. If maxLatency is greater than 0 then batches smaller than
+// the batch size are held, and messages from later polls added to them, for
+// up to maxLatency before they are handled. Failed batches are passed to the
+// subscription's FailurePolicy. Batch handlers cannot be used with workers.
+func WithCategoryBatchHandler(handler BatchHandler, maxLatency time.Duration) GetCategoryOption {
+	return func(cfg *categoryConfig) {
+		cfg.handleBatch = handler
+		cfg.batchLatency = maxLatency
+	}
+}
+
+// WithCategoryRestartPolicy supervises this category subscription, restarting
+// it from the last handled position when it fails and the policy allows it.
+// The optional handleRestart is called before each restart. Without a restart
+// policy failed subscriptions are dropped.
+func WithCategoryRestartPolicy(policy RestartPolicy, handleRestart RestartHandler) GetCategoryOption {
+	return func(cfg *categoryConfig) {
+		cfg.restartPolicy = policy
+		cfg.handleRestart = handleRestart
+	}
+}
+
+// WithCategoryLagHandler calls the handler with the lag of this category
+// subscription every interval, on its own goroutine. Lag handlers are only
+// used in subscriptions.
+func WithCategoryLagHandler(handler LagHandler, interval time.Duration) GetCategoryOption {
+	return func(cfg *categoryConfig) {
+		cfg.handleLag = handler
+		cfg.lagInterval = interval
+	}
+}
+
+// WithCategoryLivenessRules sets the rules that decide when this category
+// subscription is reported as live, so that bursts of messages do not make
+// the liveness flap.
+func WithCategoryLivenessRules(rules LivenessRules) GetCategoryOption {
+	return func(cfg *categoryConfig) {
+		cfg.livenessRules = rules
+	}
+}
+
 type categoryConfig struct {
+	subscriptionConfig
 	position            int64
 	batchSize           int64
 	correlation         string
@@ -253,13 +515,16 @@ type categoryConfig struct {
 	consumerGroupSize   int64
 	condition           string
 	pollingStrat        PollingStrategy
+	consumerID          string
+	workers             int64
 }
 
 func newDefaultCategoryConfig(strat PollingStrategy) *categoryConfig {
 	return &categoryConfig{
-		position:     0,
-		batchSize:    1000,
-		pollingStrat: strat,
+		subscriptionConfig: newDefaultSubscriptionConfig(),
+		position:           0,
+		batchSize:          1000,
+		pollingStrat:       strat,
 	}
 }
 
@@ -272,9 +537,13 @@ func (cfg *categoryConfig) validate() error {
 		return ErrInvalidConsumerGroupMember
 	} else if cfg.consumerGroupSize < 0 {
 		return ErrInvalidConsumerGroupSize
+	} else if cfg.workers < 0 {
+		return ErrInvalidWorkers
+	} else if cfg.workers > 1 && cfg.handleBatch != nil {
+		return ErrBatchHandlerWorkers
 	}
 
-	return nil
+	return cfg.subscriptionConfig.validate()
 }
 
 func (cfg *categoryConfig) getConsumerGroupMember() interface{} {
@@ -308,3 +577,64 @@ func (cfg *categoryConfig) getCondition() interface{} {
 
 	return cfg.condition
 }
+
+// subscriptionConfig holds the options shared by stream and category
+// subscriptions.
+type subscriptionConfig struct {
+	handleMessageE         MessageHandlerE
+	failurePolicy          FailurePolicy
+	positionStore          PositionStore
+	positionUpdateMessages int64
+	positionUpdateInterval time.Duration
+	channelBuffer          int64
+	handleBatch            BatchHandler
+	batchLatency           time.Duration
+	restartPolicy          RestartPolicy
+	handleRestart          RestartHandler
+	handleLag              LagHandler
+	lagInterval            time.Duration
+	livenessRules          LivenessRules
+}
+
+func newDefaultSubscriptionConfig() subscriptionConfig {
+	return subscriptionConfig{
+		positionUpdateMessages: DefaultPositionUpdateMessages,
+		positionUpdateInterval: DefaultPositionUpdateInterval,
+		channelBuffer:          DefaultChannelBuffer,
+	}
+}
+
+func (cfg *subscriptionConfig) validate() error {
+	if cfg.positionUpdateMessages < 0 || cfg.positionUpdateInterval < 0 {
+		return ErrInvalidPositionUpdateInterval
+	} else if cfg.channelBuffer < 0 {
+		return ErrInvalidChannelBuffer
+	} else if cfg.batchLatency < 0 {
+		return ErrInvalidBatchLatency
+	} else if cfg.handleLag != nil && cfg.lagInterval <= 0 {
+		return ErrInvalidLagInterval
+	}
+
+	return cfg.livenessRules.validate()
+}
+
+// handler returns the subscription's error returning message handler,
+// adapting handleMessage if no MessageHandlerE was configured.
+func (cfg *subscriptionConfig) handler(handleMessage MessageHandler) MessageHandlerE {
+	if cfg.handleMessageE != nil {
+		return cfg.handleMessageE
+	}
+
+	return func(msg *Message) error {
+		handleMessage(msg)
+		return nil
+	}
+}
+
+func (cfg *subscriptionConfig) getFailurePolicy() FailurePolicy {
+	if cfg.failurePolicy == nil {
+		return StopOnFailure()
+	}
+
+	return cfg.failurePolicy
+}
@@ -121,6 +121,25 @@ func Test_categoryConfig_validate(t *testing.T) {
 			},
 			expErr: ErrInvalidConsumerGroupSize,
 		},
+		{
+			name: "negative position update interval",
+			config: categoryConfig{
+				subscriptionConfig: subscriptionConfig{positionUpdateInterval: -time.Second},
+				position:           0,
+				batchSize:          1,
+			},
+			expErr: ErrInvalidPositionUpdateInterval,
+		},
+		{
+			name: "batch handler with workers",
+			config: categoryConfig{
+				subscriptionConfig: subscriptionConfig{handleBatch: func([]*Message) error { return nil }},
+				position:           0,
+				batchSize:          1,
+				workers:            2,
+			},
+			expErr: ErrBatchHandlerWorkers,
+		},
 		{
 			name: "valid",
 			config: categoryConfig{
@@ -165,11 +184,39 @@ func Test_streamConfig_validate(t *testing.T) {
 			},
 			expErr: ErrInvalidReadBatchSize,
 		},
+		{
+			name: "invalid message limit",
+			config: streamConfig{
+				version:      0,
+				batchSize:    1,
+				messageLimit: -1,
+			},
+			expErr: ErrInvalidReadMessageLimit,
+		},
+		{
+			name: "lag handler without interval",
+			config: streamConfig{
+				subscriptionConfig: subscriptionConfig{handleLag: func(Lag, error) {}},
+				version:            0,
+				batchSize:          1,
+			},
+			expErr: ErrInvalidLagInterval,
+		},
+		{
+			name: "negative liveness polls",
+			config: streamConfig{
+				subscriptionConfig: subscriptionConfig{livenessRules: LivenessRules{Polls: -1}},
+				version:            0,
+				batchSize:          1,
+			},
+			expErr: ErrInvalidLivenessRules,
+		},
 		{
 			name: "valid",
 			config: streamConfig{
-				version:   0,
-				batchSize: 1,
+				version:      0,
+				batchSize:    1,
+				messageLimit: 10,
 			},
 		},
 	}
@@ -0,0 +1,83 @@
+package pgxmdb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alexrudd/gomdb"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Execer executes SQL statements. It is satisfied by *pgxpool.Pool, *pgx.Conn
+// and pgx.Tx.
+type Execer interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+}
+
+// InstallNotifyTrigger installs, or replaces, the gomdb notify trigger so that
+// a category is sent as a notification on the channel whenever a message is
+// written to it.
+func InstallNotifyTrigger(ctx context.Context, db Execer, channel string) error {
+	query, err := gomdb.NotifyTriggerSQL(channel)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(ctx, query); err != nil {
+		return fmt.Errorf("installing notify trigger: %w", err)
+	}
+
+	return nil
+}
+
+// UninstallNotifyTrigger removes the gomdb notify trigger.
+func UninstallNotifyTrigger(ctx context.Context, db Execer) error {
+	if _, err := db.Exec(ctx, gomdb.DropNotifyTriggerSQL); err != nil {
+		return fmt.Errorf("uninstalling notify trigger: %w", err)
+	}
+
+	return nil
+}
+
+// Notifier is a gomdb.Notifier that LISTENs for notifications on a dedicated
+// connection taken from a pool.
+type Notifier struct {
+	pool    *pgxpool.Pool
+	channel string
+}
+
+// NewNotifier returns a Notifier that listens on the channel, which must match
+// the channel the notify trigger was installed with.
+func NewNotifier(pool *pgxpool.Pool, channel string) *Notifier {
+	return &Notifier{
+		pool:    pool,
+		channel: channel,
+	}
+}
+
+// Listen holds a connection from the pool for as long as it listens. The
+// connection is closed rather than returned to the pool once listening stops.
+func (n *Notifier) Listen(ctx context.Context, notify func(category string)) error {
+	pooled, err := n.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring connection: %w", err)
+	}
+
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{n.channel}.Sanitize()); err != nil {
+		return fmt.Errorf("listening on %s: %w", n.channel, err)
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("waiting for notification: %w", err)
+		}
+
+		notify(notification.Payload)
+	}
+}
@@ -0,0 +1,128 @@
+// Package pgxmdb provides a gomdb.Backend built on the native pgx driver, so
+// that a gomdb.Client can call Message DB procedures without going through
+// database/sql.
+package pgxmdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/alexrudd/gomdb"
+	"github.com/jackc/pgx/v5"
+)
+
+// DB is the pgx database handle used to call Message DB procedures. It is
+// satisfied by *pgxpool.Pool, *pgx.Conn and pgx.Tx.
+type DB interface {
+	Query(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// txBeginner is implemented by DBs that can start a transaction with options,
+// such as *pgxpool.Pool and *pgx.Conn.
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error)
+}
+
+// NewClient returns a new message-db client for the provided pgx database.
+// The search path of the database connections must include the
+// message_store schema. pgx caches prepared statements per connection, so
+// closing the Client is not required.
+func NewClient(db DB, opts ...gomdb.ClientOption) *gomdb.Client {
+	return gomdb.NewClientWithBackend(NewBackend(db), opts...)
+}
+
+// NewBackend returns a gomdb.Backend for the provided pgx database.
+func NewBackend(db DB) gomdb.Backend {
+	return &backend{db: db}
+}
+
+type backend struct {
+	db DB
+}
+
+func (b *backend) Query(ctx context.Context, query string, args ...interface{}) (gomdb.Rows, error) {
+	r, err := b.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return rows{r}, nil
+}
+
+// BeginTx starts a new transaction. If the DB is already a pgx.Tx then a
+// nested transaction is started using a savepoint, and the options are
+// ignored.
+func (b *backend) BeginTx(ctx context.Context, opts *sql.TxOptions) (gomdb.TxBackend, error) {
+	var (
+		tx  pgx.Tx
+		err error
+	)
+
+	if beginner, ok := b.db.(txBeginner); ok && opts != nil {
+		txOpts, optErr := txOptions(opts)
+		if optErr != nil {
+			return nil, optErr
+		}
+		tx, err = beginner.BeginTx(ctx, txOpts)
+	} else {
+		tx, err = b.db.Begin(ctx)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &txBackend{backend: backend{db: tx}, tx: tx}, nil
+}
+
+type txBackend struct {
+	backend
+	tx pgx.Tx
+}
+
+func (b *txBackend) Commit(ctx context.Context) error {
+	return b.tx.Commit(ctx)
+}
+
+func (b *txBackend) Rollback(ctx context.Context) error {
+	return b.tx.Rollback(ctx)
+}
+
+// rows adapts pgx.Rows to gomdb.Rows.
+type rows struct {
+	pgx.Rows
+}
+
+func (r rows) Close() error {
+	r.Rows.Close()
+
+	return r.Rows.Err()
+}
+
+// txOptions converts database/sql transaction options to their pgx
+// equivalent.
+func txOptions(opts *sql.TxOptions) (pgx.TxOptions, error) {
+	txOpts := pgx.TxOptions{}
+
+	switch opts.Isolation {
+	case sql.LevelDefault:
+	case sql.LevelReadUncommitted:
+		txOpts.IsoLevel = pgx.ReadUncommitted
+	case sql.LevelReadCommitted:
+		txOpts.IsoLevel = pgx.ReadCommitted
+	case sql.LevelRepeatableRead, sql.LevelSnapshot:
+		txOpts.IsoLevel = pgx.RepeatableRead
+	case sql.LevelSerializable:
+		txOpts.IsoLevel = pgx.Serializable
+	default:
+		return txOpts, fmt.Errorf("unsupported isolation level: %s", opts.Isolation)
+	}
+
+	if opts.ReadOnly {
+		txOpts.AccessMode = pgx.ReadOnly
+	}
+
+	return txOpts, nil
+}
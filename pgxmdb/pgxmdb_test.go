@@ -0,0 +1,77 @@
+package pgxmdb
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/alexrudd/gomdb"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	_ DB = (*pgxpool.Pool)(nil)
+	_ DB = (*pgx.Conn)(nil)
+	_ DB = (pgx.Tx)(nil)
+
+	_ Execer = (*pgxpool.Pool)(nil)
+	_ Execer = (*pgx.Conn)(nil)
+	_ Execer = (pgx.Tx)(nil)
+
+	_ gomdb.Notifier = (*Notifier)(nil)
+)
+
+func Test_txOptions(t *testing.T) {
+	testcases := []struct {
+		name    string
+		opts    sql.TxOptions
+		expOpts pgx.TxOptions
+		expErr  bool
+	}{
+		{
+			name:    "default",
+			opts:    sql.TxOptions{},
+			expOpts: pgx.TxOptions{},
+		},
+		{
+			name: "read only repeatable read",
+			opts: sql.TxOptions{
+				Isolation: sql.LevelRepeatableRead,
+				ReadOnly:  true,
+			},
+			expOpts: pgx.TxOptions{
+				IsoLevel:   pgx.RepeatableRead,
+				AccessMode: pgx.ReadOnly,
+			},
+		},
+		{
+			name: "serializable",
+			opts: sql.TxOptions{
+				Isolation: sql.LevelSerializable,
+			},
+			expOpts: pgx.TxOptions{
+				IsoLevel: pgx.Serializable,
+			},
+		},
+		{
+			name: "unsupported",
+			opts: sql.TxOptions{
+				Isolation: sql.LevelLinearizable,
+			},
+			expErr: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			opts, err := txOptions(&tc.opts)
+			if (err != nil) != tc.expErr {
+				t.Fatalf("expected error: %v, actual %v", tc.expErr, err)
+			} else if opts != tc.expOpts {
+				t.Fatalf("expected %+v, actual %+v", tc.expOpts, opts)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package gomdb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// PositionCategoryType is the category type of the streams used to record
+	// consumer positions, as in "account:position-consumerId".
+	PositionCategoryType = "position"
+	// PositionRecordedType is the message type of recorded consumer
+	// positions.
+	PositionRecordedType = "Recorded"
+	// DefaultPositionUpdateMessages is the default number of messages handled
+	// between position updates.
+	DefaultPositionUpdateMessages = int64(100)
+	// DefaultPositionUpdateInterval is the default maximum duration between
+	// position updates.
+	DefaultPositionUpdateInterval = 5 * time.Second
+)
+
+// PositionStream returns the stream used to record the position of a
+// category consumer: "category:position-consumerId".
+func PositionStream(category, consumerID string) StreamIdentifier {
+	si := StreamIdentifier{Category: category}
+
+	return NewStreamIdentifier(si.EntityCategory(), append(si.Types(), PositionCategoryType), consumerID)
+}
+
+// PositionRecorded is the data of a message recording a consumer's position.
+// Position is the global position of the last handled message.
+type PositionRecorded struct {
+	Position int64 `json:"position"`
+}
+
+// PositionStore reads and records the position of a subscription, so that a
+// restarted subscription can resume from where it left off. Positions are the
+// stream version or global position of the last handled message.
+type PositionStore interface {
+	// Get returns the last recorded position, or -1 if no position has been
+	// recorded.
+	Get(ctx context.Context) (int64, error)
+	// Put records the position.
+	Put(ctx context.Context, position int64) error
+}
+
+// MessageDBPositionStore is a PositionStore that records positions as
+// PositionRecorded messages in a Message DB stream.
+type MessageDBPositionStore struct {
+	client *Client
+	stream StreamIdentifier
+}
+
+// NewMessageDBPositionStore returns a PositionStore that records positions in
+// the specified stream, which is usually created with PositionStream.
+func NewMessageDBPositionStore(client *Client, stream StreamIdentifier) *MessageDBPositionStore {
+	return &MessageDBPositionStore{
+		client: client,
+		stream: stream,
+	}
+}
+
+// Get returns the position recorded by the last message in the position
+// stream, or -1 if the stream is empty.
+func (s *MessageDBPositionStore) Get(ctx context.Context) (int64, error) {
+	msg, err := s.client.GetLastStreamMessage(ctx, s.stream)
+	if err != nil {
+		return 0, fmt.Errorf("reading position stream: %w", err)
+	} else if msg == nil {
+		return -1, nil
+	}
+
+	recorded := PositionRecorded{}
+	if err := msg.UnmarshalData(&recorded); err != nil {
+		return 0, fmt.Errorf("unmarshaling recorded position: %w", err)
+	}
+
+	return recorded.Position, nil
+}
+
+// Put writes a PositionRecorded message to the position stream.
+func (s *MessageDBPositionStore) Put(ctx context.Context, position int64) error {
+	id, err := newMessageID()
+	if err != nil {
+		return fmt.Errorf("generating position message ID: %w", err)
+	}
+
+	_, err = s.client.WriteMessage(ctx, s.stream, ProposedMessage{
+		ID:   id,
+		Type: PositionRecordedType,
+		Data: PositionRecorded{Position: position},
+	}, AnyVersion)
+	if err != nil {
+		return fmt.Errorf("writing position stream: %w", err)
+	}
+
+	return nil
+}
+
+// SQLPositionStore is a PositionStore that records positions in a Postgres
+// table, keyed by consumer ID. The table can be created with CreateTable and
+// has the schema:
+//
+//	CREATE TABLE positions (
+//		consumer_id text PRIMARY KEY,
+//		position bigint NOT NULL
+//	)
+type SQLPositionStore struct {
+	db         DB
+	table      string
+	consumerID string
+}
+
+// NewSQLPositionStore returns a PositionStore that records the consumer's
+// position in the specified table. The table name is used in queries as is,
+// so it may include a schema but must not come from untrusted input.
+func NewSQLPositionStore(db DB, table, consumerID string) *SQLPositionStore {
+	return &SQLPositionStore{
+		db:         db,
+		table:      table,
+		consumerID: consumerID,
+	}
+}
+
+// WithDB returns a copy of the store that uses a different DB. Use it with a
+// *sql.Tx to record a position in the same transaction as a read model update.
+func (s *SQLPositionStore) WithDB(db DB) *SQLPositionStore {
+	return NewSQLPositionStore(db, s.table, s.consumerID)
+}
+
+// CreateTable creates the store's table if it does not already exist.
+func (s *SQLPositionStore) CreateTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (consumer_id text PRIMARY KEY, position bigint NOT NULL)",
+		s.table,
+	))
+	if err != nil {
+		return fmt.Errorf("creating position table: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the consumer's recorded position, or -1 if it has no row.
+func (s *SQLPositionStore) Get(ctx context.Context) (int64, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT position FROM %s WHERE consumer_id = $1",
+		s.table,
+	), s.consumerID)
+	if err != nil {
+		return 0, fmt.Errorf("reading position table: %w", err)
+	}
+	defer rows.Close()
+
+	position := int64(-1)
+	if rows.Next() {
+		if err := rows.Scan(&position); err != nil {
+			return 0, fmt.Errorf("scanning position: %w", err)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("reading position table: %w", err)
+	}
+
+	return position, nil
+}
+
+// Put inserts or updates the consumer's position.
+func (s *SQLPositionStore) Put(ctx context.Context, position int64) error {
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (consumer_id, position) VALUES ($1, $2) "+
+			"ON CONFLICT (consumer_id) DO UPDATE SET position = EXCLUDED.position",
+		s.table,
+	), s.consumerID, position)
+	if err != nil {
+		return fmt.Errorf("writing position table: %w", err)
+	}
+
+	return nil
+}
+
+// MemoryPositionStore is a PositionStore that holds the position in memory.
+// It is safe for concurrent use, and is mostly useful in tests.
+type MemoryPositionStore struct {
+	mu       sync.Mutex
+	position int64
+}
+
+// NewMemoryPositionStore returns an empty in-memory PositionStore.
+func NewMemoryPositionStore() *MemoryPositionStore {
+	return &MemoryPositionStore{position: -1}
+}
+
+// Get returns the last position put in the store, or -1.
+func (s *MemoryPositionStore) Get(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.position, nil
+}
+
+// Put records the position.
+func (s *MemoryPositionStore) Put(ctx context.Context, position int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.position = position
+
+	return nil
+}
+
+// positionTracker returns a tracker for the configured position store along
+// with the last recorded position, or a nil tracker and -1 if no store has
+// been configured.
+func (cfg *subscriptionConfig) positionTracker(ctx context.Context) (*positionTracker, int64, error) {
+	if cfg.positionStore == nil {
+		return nil, -1, nil
+	}
+
+	recorded, err := cfg.positionStore.Get(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading subscription position: %w", err)
+	}
+
+	return newPositionTracker(cfg.positionStore, cfg.positionUpdateMessages, cfg.positionUpdateInterval, recorded), recorded, nil
+}
+
+// positionTracker records a subscription's position every N handled messages
+// or every interval, whichever comes first.
+type positionTracker struct {
+	store    PositionStore
+	messages int64
+	interval time.Duration
+
+	handled  int64
+	position int64
+	recorded int64
+	last     time.Time
+}
+
+func newPositionTracker(store PositionStore, messages int64, interval time.Duration, position int64) *positionTracker {
+	return &positionTracker{
+		store:    store,
+		messages: messages,
+		interval: interval,
+		position: position,
+		recorded: position,
+		last:     time.Now(),
+	}
+}
+
+// handle notes that the specified number of messages up to and including
+// position have been handled, recording the position if an update is due.
+func (pt *positionTracker) handle(ctx context.Context, position, messages int64) error {
+	pt.position = position
+	pt.handled += messages
+
+	if pt.messages > 0 && pt.handled >= pt.messages {
+		return pt.record(ctx)
+	}
+
+	return pt.tick(ctx)
+}
+
+// tick records the position if it has changed and the interval has elapsed.
+func (pt *positionTracker) tick(ctx context.Context) error {
+	if pt.interval > 0 && pt.position != pt.recorded && time.Since(pt.last) >= pt.interval {
+		return pt.record(ctx)
+	}
+
+	return nil
+}
+
+// flush records the position if it has changed since it was last recorded.
+func (pt *positionTracker) flush(ctx context.Context) error {
+	if pt.position != pt.recorded {
+		return pt.record(ctx)
+	}
+
+	return nil
+}
+
+func (pt *positionTracker) record(ctx context.Context) error {
+	if err := pt.store.Put(ctx, pt.position); err != nil {
+		return err
+	}
+
+	pt.recorded = pt.position
+	pt.handled = 0
+	pt.last = time.Now()
+
+	return nil
+}
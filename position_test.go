@@ -0,0 +1,109 @@
+package gomdb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakePositionStore records every position put to it.
+type fakePositionStore struct {
+	position int64
+	puts     []int64
+}
+
+func (s *fakePositionStore) Get(ctx context.Context) (int64, error) {
+	return s.position, nil
+}
+
+func (s *fakePositionStore) Put(ctx context.Context, position int64) error {
+	s.position = position
+	s.puts = append(s.puts, position)
+	return nil
+}
+
+func Test_PositionStream(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		category string
+		expected string
+	}{
+		{category: "account", expected: "account:position-worker1"},
+		{category: "account:command", expected: "account:command+position-worker1"},
+	}
+
+	for _, tc := range testcases {
+		if actual := PositionStream(tc.category, "worker1").String(); actual != tc.expected {
+			t.Fatalf("expected %s, actual %s", tc.expected, actual)
+		}
+	}
+}
+
+func Test_positionTracker(t *testing.T) {
+	t.Parallel()
+
+	t.Run("records every N messages", func(t *testing.T) {
+		t.Parallel()
+
+		store := &fakePositionStore{}
+		tracker := newPositionTracker(store, 3, 0, -1)
+
+		for position := int64(0); position < 10; position++ {
+			if err := tracker.handle(context.TODO(), position, 1); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if len(store.puts) != 3 || store.position != 8 {
+			t.Fatalf("expected 3 puts ending at 8, actual %v", store.puts)
+		}
+	})
+
+	t.Run("records after interval", func(t *testing.T) {
+		t.Parallel()
+
+		store := &fakePositionStore{}
+		tracker := newPositionTracker(store, 0, time.Millisecond, -1)
+
+		if err := tracker.tick(context.TODO()); err != nil {
+			t.Fatal(err)
+		} else if len(store.puts) != 0 {
+			t.Fatalf("expected unchanged position not to be recorded, actual %v", store.puts)
+		}
+
+		if err := tracker.handle(context.TODO(), 5, 1); err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(2 * time.Millisecond)
+
+		if err := tracker.tick(context.TODO()); err != nil {
+			t.Fatal(err)
+		} else if len(store.puts) != 1 || store.position != 5 {
+			t.Fatalf("expected position 5 to be recorded, actual %v", store.puts)
+		}
+	})
+}
+
+func Test_MemoryPositionStore(t *testing.T) {
+	t.Parallel()
+
+	var store PositionStore = NewMemoryPositionStore()
+
+	if position, err := store.Get(context.TODO()); err != nil {
+		t.Fatal(err)
+	} else if position != -1 {
+		t.Fatalf("expected empty store to return -1, actual %v", position)
+	}
+
+	if err := store.Put(context.TODO(), 42); err != nil {
+		t.Fatal(err)
+	}
+
+	if position, err := store.Get(context.TODO()); err != nil {
+		t.Fatal(err)
+	} else if position != 42 {
+		t.Fatalf("expected 42, actual %v", position)
+	}
+}
@@ -0,0 +1,126 @@
+package gomdb
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// RestartPolicy decides whether a failed subscription is restarted, and how
+// long to wait before restarting it. attempt counts the restarts since the
+// subscription last polled successfully, starting at 1.
+type RestartPolicy func(attempt int, err error) (delay time.Duration, restart bool)
+
+// SubscriptionRestart describes a subscription that is being restarted.
+type SubscriptionRestart struct {
+	// Attempt counts the restarts since the subscription last polled
+	// successfully, starting at 1.
+	Attempt int
+	// Err is the error that stopped the subscription.
+	Err error
+	// Delay is how long the subscription waits before restarting.
+	Delay time.Duration
+	// Position is the position from which the subscription will resume.
+	Position int64
+}
+
+// RestartHandler is called before a failed subscription is restarted. If it,
+// or the RestartPolicy, panics the subscription is not restarted and stops
+// with a *PanicError.
+type RestartHandler func(SubscriptionRestart)
+
+var (
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// RestartWithBackoff returns a RestartPolicy that restarts subscriptions that
+// failed with a transient error, as classified by isTransient, up to the
+// specified number of consecutive restarts. If restarts is 0 then there is no
+// limit, and if isTransient is nil then IsTransientError is used. The delay
+// before each restart starts at the min duration and is multiplied after every
+// attempt up to the max duration. A random jitter of up to half the delay is
+// subtracted so that subscriptions failing together do not restart together.
+func RestartWithBackoff(restarts int, min, max time.Duration, multiplier float64, isTransient func(error) bool) RestartPolicy {
+	if isTransient == nil {
+		isTransient = IsTransientError
+	}
+
+	return func(attempt int, err error) (time.Duration, bool) {
+		if (restarts > 0 && attempt > restarts) || !isTransient(err) {
+			return 0, false
+		}
+
+		delay := backoff(attempt-1, min, max, multiplier)
+		if half := int64(delay / 2); half > 0 {
+			jitterMu.Lock()
+			delay -= time.Duration(jitterRand.Int63n(half + 1))
+			jitterMu.Unlock()
+		}
+
+		return delay, true
+	}
+}
+
+// IsTransientError reports whether the error is likely to be temporary, such
+// as a dropped connection, a timeout, or a Postgres error in the connection
+// exception, insufficient resources, operator intervention or transaction
+// rollback classes. Any other error, including handler errors, is treated as
+// fatal.
+func IsTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	for _, target := range []error{
+		driver.ErrBadConn,
+		io.EOF,
+		io.ErrUnexpectedEOF,
+		syscall.ECONNRESET,
+		syscall.ECONNREFUSED,
+		syscall.ECONNABORTED,
+		syscall.EPIPE,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	state := sqlState(err)
+	for _, prefix := range []string{"08", "40", "53", "57P"} {
+		if state != "" && strings.HasPrefix(state, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sqlState returns the SQLSTATE code of a Postgres error, or "" if the error
+// does not have one. pgx errors report it with SQLState, while lib/pq errors
+// report it as the 'C' field, read with Get.
+func sqlState(err error) string {
+	var pgxErr interface{ SQLState() string }
+	if errors.As(err, &pgxErr) {
+		return pgxErr.SQLState()
+	}
+
+	var pqErr interface{ Get(byte) string }
+	if errors.As(err, &pqErr) {
+		return pqErr.Get('C')
+	}
+
+	return ""
+}
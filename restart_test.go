@@ -0,0 +1,206 @@
+package gomdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// sqlStateError is an error with a SQLSTATE code, like those returned by pgx.
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "sqlstate " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+// pqError is an error with a SQLSTATE code in its 'C' field, like those
+// returned by lib/pq.
+type pqError string
+
+func (e pqError) Error() string { return "pq: " + string(e) }
+
+func (e pqError) Get(field byte) string {
+	if field == 'C' {
+		return string(e)
+	}
+	return ""
+}
+
+func Test_IsTransientError(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "nil", err: nil, expected: false},
+		{name: "handler error", err: errors.New("handler failed"), expected: false},
+		{name: "cancelled", err: context.Canceled, expected: false},
+		{name: "connection reset", err: fmt.Errorf("reading: %w", syscall.ECONNRESET), expected: true},
+		{name: "network error", err: &net.OpError{Op: "read", Err: errors.New("broken")}, expected: true},
+		{name: "admin shutdown", err: fmt.Errorf("polling: %w", sqlStateError("57P01")), expected: true},
+		{name: "connection failure", err: sqlStateError("08006"), expected: true},
+		{name: "unique violation", err: sqlStateError("23505"), expected: false},
+		{name: "pq admin shutdown", err: fmt.Errorf("polling: %w", pqError("57P01")), expected: true},
+		{name: "pq serialization failure", err: pqError("40001"), expected: true},
+		{name: "pq unique violation", err: pqError("23505"), expected: false},
+	}
+
+	for _, tc := range testcases {
+		if actual := IsTransientError(tc.err); actual != tc.expected {
+			t.Fatalf("%s: expected %v, actual %v", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func Test_RestartWithBackoff(t *testing.T) {
+	t.Parallel()
+
+	policy := RestartWithBackoff(3, 100*time.Millisecond, 300*time.Millisecond, 2, nil)
+	transient := syscall.ECONNRESET
+
+	for attempt, max := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond} {
+		delay, restart := policy(attempt+1, transient)
+		if !restart {
+			t.Fatalf("attempt %v: expected restart", attempt+1)
+		} else if delay < max/2 || delay > max {
+			t.Fatalf("attempt %v: expected delay between %v and %v, actual %v", attempt+1, max/2, max, delay)
+		}
+	}
+
+	if _, restart := policy(4, transient); restart {
+		t.Fatal("expected policy to give up after the budget")
+	} else if _, restart := policy(1, errors.New("handler failed")); restart {
+		t.Fatal("expected fatal errors not to be restarted")
+	}
+}
+
+func Test_runSubscription_restart(t *testing.T) {
+	t.Parallel()
+
+	t.Run("resumes after transient errors", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		sub := newSubscription(cancel, 0)
+		msgs := testMessages(10)
+
+		var (
+			restarts []SubscriptionRestart
+			handled  []int64
+			polls    int
+		)
+
+		loop := testLoop(msgs, 4)
+		poll := loop.poll
+		loop.poll = func(ctx context.Context) ([]*Message, error) {
+			// the second and third polls fail.
+			if polls++; polls == 2 || polls == 3 {
+				return nil, syscall.ECONNRESET
+			}
+			return poll(ctx)
+		}
+		loop.seek = func(position int64) {
+			loop.advance(msgs[position-1])
+		}
+		loop.handleMessage = func(msg *Message) error {
+			handled = append(handled, msg.Version)
+			return nil
+		}
+		loop.handleLiveness = func(live bool) {
+			if live {
+				sub.Stop()
+			}
+		}
+		loop.restartPolicy = RestartWithBackoff(2, time.Millisecond, time.Millisecond, 1, nil)
+		loop.handleRestart = func(restart SubscriptionRestart) {
+			restarts = append(restarts, restart)
+		}
+
+		go (&Client{}).runSubscription(ctx, sub, loop)
+
+		if err := sub.Wait(); err != nil {
+			t.Fatal(err)
+		} else if len(restarts) != 2 || restarts[0].Attempt != 1 || restarts[1].Attempt != 2 || restarts[1].Position != 4 {
+			t.Fatalf("expected 2 restarts from position 4, actual %+v", restarts)
+		} else if len(handled) != 10 {
+			t.Fatalf("expected each message to be handled once, actual %v", handled)
+		}
+	})
+
+	t.Run("gives up after budget", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		sub := newSubscription(cancel, 0)
+		restarts := 0
+
+		loop := testLoop(nil, 4)
+		loop.poll = func(ctx context.Context) ([]*Message, error) {
+			return nil, syscall.ECONNRESET
+		}
+		loop.seek = func(int64) {}
+		loop.restartPolicy = RestartWithBackoff(3, time.Millisecond, time.Millisecond, 1, nil)
+		loop.handleRestart = func(SubscriptionRestart) {
+			restarts++
+		}
+
+		go (&Client{}).runSubscription(ctx, sub, loop)
+
+		if err := sub.Wait(); !errors.Is(err, syscall.ECONNRESET) {
+			t.Fatalf("expected connection reset error, actual %v", err)
+		} else if restarts != 3 {
+			t.Fatalf("expected 3 restarts, actual %v", restarts)
+		}
+	})
+
+	t.Run("does not restart fatal errors", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		sub := newSubscription(cancel, 0)
+		errHandler := errors.New("handler failed")
+
+		loop := testLoop(testMessages(10), 4)
+		loop.handleMessage = func(*Message) error { return errHandler }
+		loop.seek = func(int64) {}
+		loop.restartPolicy = RestartWithBackoff(0, time.Millisecond, time.Millisecond, 1, nil)
+		loop.handleRestart = func(SubscriptionRestart) {
+			t.Error("expected no restarts")
+		}
+
+		go (&Client{}).runSubscription(ctx, sub, loop)
+
+		if err := sub.Wait(); !errors.Is(err, errHandler) {
+			t.Fatalf("expected %v, actual %v", errHandler, err)
+		}
+	})
+
+	t.Run("stops when the restart handler panics", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		sub := newSubscription(cancel, 0)
+
+		loop := testLoop(nil, 4)
+		loop.poll = func(ctx context.Context) ([]*Message, error) {
+			return nil, syscall.ECONNRESET
+		}
+		loop.seek = func(int64) {}
+		loop.restartPolicy = RestartWithBackoff(0, time.Millisecond, time.Millisecond, 1, nil)
+		loop.handleRestart = func(SubscriptionRestart) {
+			panic("restart handler failed")
+		}
+
+		go (&Client{}).runSubscription(ctx, sub, loop)
+
+		var panicErr *PanicError
+		if err := sub.Wait(); !errors.As(err, &panicErr) || panicErr.Value != "restart handler failed" {
+			t.Fatalf("expected restart handler panic, actual %v", err)
+		}
+	})
+}
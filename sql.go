@@ -35,4 +35,18 @@ const (
 	GetLastStreamMessageSQL = "SELECT * FROM get_last_stream_message($1)"
 	// StreamVersionSQL with (stream_name)
 	GetStreamVersionSQL = "SELECT * FROM stream_version($1)"
+	// GetHeadPositionSQL returns the global position of the last message, or
+	// -1 if there are none.
+	GetHeadPositionSQL = "SELECT COALESCE(MAX(global_position), -1) FROM messages"
+	// GetCategoryHeadPositionSQL with (category_name)
+	GetCategoryHeadPositionSQL = "SELECT COALESCE(MAX(global_position), -1) FROM messages WHERE category(stream_name) = $1"
+	// CountCategoryMessagesSQL with (
+	//   category_name,
+	//   position,
+	//   consumer_group_member,
+	//   consumer_group_size,
+	//   limit
+	// )
+	CountCategoryMessagesSQL = "SELECT COUNT(*) FROM (SELECT 1 FROM messages WHERE category(stream_name) = $1 AND global_position >= $2 " +
+		"AND ($4::bigint IS NULL OR MOD(@hash_64(cardinal_id(stream_name)), $4::bigint) = $3::bigint) LIMIT $5) AS messages"
 )
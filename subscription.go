@@ -0,0 +1,724 @@
+package gomdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MessageHandler handles messages as they appear after being written.
+type MessageHandler func(*Message)
+
+// LivenessHandler handles whether the subscription is in a "live" state or
+// whether it is catching up.
+type LivenessHandler func(bool)
+
+// SubDroppedHandler handles errors that appear and stop the subscription.
+type SubDroppedHandler func(error)
+
+// SubscriptionError is the error that stopped a subscription, as passed to
+// the SubDroppedHandler and returned by Subscription.Wait.
+type SubscriptionError struct {
+	// Stream is the stream subscribed to, or the category for category
+	// subscriptions (in which case Stream.ID is empty). For merged
+	// subscriptions the category is the categories joined by commas.
+	Stream StreamIdentifier
+	// Position is the position the subscription had reached, as returned by
+	// Subscription.Position.
+	Position int64
+	// Attempt is the number of polls the subscription had attempted,
+	// including the one during which it failed.
+	Attempt int
+	// Err is the cause.
+	Err error
+}
+
+func (e *SubscriptionError) Error() string {
+	return fmt.Sprintf("subscription to %s stopped at position %d on poll %d: %v", e.Stream, e.Position, e.Attempt, e.Err)
+}
+
+func (e *SubscriptionError) Unwrap() error {
+	return e.Err
+}
+
+// errDrained is returned by pollSubscription once a drained subscription has
+// handled the messages it read.
+var errDrained = errors.New("subscription drained")
+
+// Subscription is a handle to a running subscription. It can be used to stop
+// or drain the subscription, and to wait until its goroutine has exited.
+type Subscription struct {
+	cancel    context.CancelFunc
+	done      chan struct{}
+	draining  chan struct{}
+	drainOnce sync.Once
+	err       error
+	position  int64
+	// lag returns the lag of the subscription when it will next read from
+	// position.
+	lag func(ctx context.Context, position int64, query lagQuery) (Lag, error)
+}
+
+func newSubscription(cancel context.CancelFunc, position int64) *Subscription {
+	return &Subscription{
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		draining: make(chan struct{}),
+		position: position,
+	}
+}
+
+// Stop stops the subscription. Stop does not wait for the subscription to
+// exit, use Wait or Done for that.
+func (s *Subscription) Stop() {
+	s.cancel()
+}
+
+// Drain stops the subscription from polling, and waits for it to handle the
+// messages it has already read and to record its position before exiting. It
+// returns the *SubscriptionError that stopped the subscription, or nil once
+// it has been drained or if it had already been stopped. If the context ends
+// first then the subscription is stopped as if by Stop, without waiting for
+// its handlers to return, and the context's error is returned.
+func (s *Subscription) Drain(ctx context.Context) error {
+	s.drainOnce.Do(func() {
+		close(s.draining)
+	})
+
+	select {
+	case <-s.done:
+		return s.err
+	case <-ctx.Done():
+		s.cancel()
+		return ctx.Err()
+	}
+}
+
+// Done returns a channel that is closed once the subscription has exited and
+// its handlers will no longer be called.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Wait blocks until the subscription has exited and returns the
+// *SubscriptionError that stopped it, or nil if the subscription was stopped
+// or cancelled.
+func (s *Subscription) Wait() error {
+	<-s.done
+
+	return s.err
+}
+
+// Position returns the position from which the subscription will next read.
+// For stream subscriptions this is the next stream version, and for category
+// subscriptions it is the next global position.
+func (s *Subscription) Position() int64 {
+	return atomic.LoadInt64(&s.position)
+}
+
+func (s *Subscription) setPosition(position int64) {
+	atomic.StoreInt64(&s.position, position)
+}
+
+func (s *Subscription) isDraining() bool {
+	select {
+	case <-s.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+// SubscribeToStream subscribes to a stream and asynchronously passes messages
+// to the message handler in batches. Once a subscription has caught up it will
+// poll the database periodically for new messages. To stop a subscription
+// call Stop on the returned Subscription, or cancel the provided context.
+// When a subscription catches up it will call the LivenessHandler with true. If
+// the subscription falls behind again it will called the LivenessHandler with
+// false.
+// WithStreamLivenessRules changes when the subscription is considered live.
+// If there is an error while reading messages then the subscription will be
+// stopped and the SubDroppedHandler will be called with a *SubscriptionError
+// wrapping the stopping error. If the subscription is cancelled or drained
+// then the SubDroppedHandler will be called with nil.
+// To handle messages that can fail, pass a nil MessageHandler and set a
+// MessageHandlerE with WithStreamMessageHandlerE. Failed messages are passed to
+// the FailurePolicy set with WithStreamFailurePolicy, which stops the
+// subscription by default.
+// To resume the subscription after a restart, set a PositionStore with
+// WithStreamPositionStore.
+func (c *Client) SubscribeToStream(
+	ctx context.Context,
+	stream StreamIdentifier,
+	handleMessage MessageHandler,
+	handleLiveness LivenessHandler,
+	handleDropped SubDroppedHandler,
+	opts ...GetStreamOption,
+) (*Subscription, error) {
+	cfg := newDefaultStreamConfig(c.defaultPollingStrat())
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	// validate inputs
+	if err := stream.validate(); err != nil {
+		return nil, fmt.Errorf("validating stream identifier: %w", err)
+	} else if (handleMessage == nil && cfg.handleMessageE == nil && cfg.handleBatch == nil) || handleLiveness == nil || handleDropped == nil {
+		return nil, errors.New("all subscription handlers are required")
+	} else if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("validating options: %w", err)
+	}
+
+	tracker, recorded, err := cfg.positionTracker(ctx)
+	if err != nil {
+		return nil, err
+	} else if recorded >= 0 {
+		cfg.version = recorded + 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	sub := newSubscription(cancel, cfg.version)
+	sub.lag = func(ctx context.Context, version int64, query lagQuery) (Lag, error) {
+		return c.streamLag(ctx, stream, version, query)
+	}
+
+	go c.runSubscription(ctx, sub, subscriptionLoop{
+		batchSize:    cfg.batchSize,
+		pollingStrat: cfg.pollingStrat,
+		stream:       stream,
+		tracker:      tracker,
+		poll: func(ctx context.Context) ([]*Message, error) {
+			return c.GetStreamMessages(ctx, stream, func(c *streamConfig) { *c = *cfg })
+		},
+		advance: func(msg *Message) int64 {
+			cfg.version = msg.Version + 1
+			return cfg.version
+		},
+		seek: func(position int64) {
+			cfg.version = position
+		},
+		handleBatch:    cfg.handleBatch,
+		batchLatency:   cfg.batchLatency,
+		handleMessage:  cfg.handler(handleMessage),
+		failurePolicy:  cfg.getFailurePolicy(),
+		handleLiveness: handleLiveness,
+		handleDropped:  handleDropped,
+		restartPolicy:  cfg.restartPolicy,
+		handleRestart:  cfg.handleRestart,
+		handleLag:      cfg.handleLag,
+		lagInterval:    cfg.lagInterval,
+		liveness:       liveness{rules: cfg.livenessRules},
+	})
+
+	return sub, nil
+}
+
+// SubscribeToCategory subscribes to a category and asynchronously passes messages
+// to the message handler in batches. Once a subscription has caught up it will
+// poll the database periodically for new messages. To stop a subscription
+// call Stop on the returned Subscription, or cancel the provided context.
+// When a subscription catches up it will call the LivenessHandler with true. If
+// the subscription falls behind again it will called the LivenessHandler with
+// false.
+// WithCategoryLivenessRules changes when the subscription is considered live.
+// If there is an error while reading messages then the subscription will be
+// stopped and the SubDroppedHandler will be called with a *SubscriptionError
+// wrapping the stopping error. If the subscription is cancelled or drained
+// then the SubDroppedHandler will be called with nil.
+// To handle messages that can fail, pass a nil MessageHandler and set a
+// MessageHandlerE with WithCategoryMessageHandlerE. Failed messages are passed to
+// the FailurePolicy set with WithCategoryFailurePolicy, which stops the
+// subscription by default.
+func (c *Client) SubscribeToCategory(
+	ctx context.Context,
+	category string,
+	handleMessage MessageHandler,
+	handleLiveness LivenessHandler,
+	handleDropped SubDroppedHandler,
+	opts ...GetCategoryOption,
+) (*Subscription, error) {
+	cfg := newDefaultCategoryConfig(c.defaultPollingStrat())
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	// validate inputs
+	if err := validateCategory(category); err != nil {
+		return nil, fmt.Errorf("validating category: %w", err)
+	} else if (handleMessage == nil && cfg.handleMessageE == nil && cfg.handleBatch == nil) || handleLiveness == nil || handleDropped == nil {
+		return nil, errors.New("all subscription handlers are required")
+	} else if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("validating options: %w", err)
+	}
+
+	// durable consumers record their position in a position stream.
+	if cfg.positionStore == nil && cfg.consumerID != "" {
+		cfg.positionStore = NewMessageDBPositionStore(c, PositionStream(category, cfg.consumerID))
+	}
+
+	tracker, recorded, err := cfg.positionTracker(ctx)
+	if err != nil {
+		return nil, err
+	} else if recorded >= 0 {
+		cfg.position = recorded + 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	sub := newSubscription(cancel, cfg.position)
+	member, size := cfg.consumerGroupMember, cfg.consumerGroupSize
+	sub.lag = func(ctx context.Context, position int64, query lagQuery) (Lag, error) {
+		return c.categoryLag(ctx, category, position, member, size, query)
+	}
+
+	go c.runSubscription(ctx, sub, subscriptionLoop{
+		batchSize:    cfg.batchSize,
+		pollingStrat: cfg.pollingStrat,
+		stream:       StreamIdentifier{Category: category},
+		tracker:      tracker,
+		workers:      cfg.workers,
+		poll: func(ctx context.Context) ([]*Message, error) {
+			return c.GetCategoryMessages(ctx, category, func(c *categoryConfig) { *c = *cfg })
+		},
+		advance: func(msg *Message) int64 {
+			cfg.position = msg.GlobalPosition + 1
+			return cfg.position
+		},
+		seek: func(position int64) {
+			cfg.position = position
+		},
+		handleBatch:    cfg.handleBatch,
+		batchLatency:   cfg.batchLatency,
+		handleMessage:  cfg.handler(handleMessage),
+		failurePolicy:  cfg.getFailurePolicy(),
+		handleLiveness: handleLiveness,
+		handleDropped:  handleDropped,
+		restartPolicy:  cfg.restartPolicy,
+		handleRestart:  cfg.handleRestart,
+		handleLag:      cfg.handleLag,
+		lagInterval:    cfg.lagInterval,
+		liveness:       liveness{rules: cfg.livenessRules},
+	})
+
+	return sub, nil
+}
+
+// subscriptionLoop holds what differs between stream and category
+// subscriptions.
+type subscriptionLoop struct {
+	batchSize    int64
+	pollingStrat PollingStrategy
+	// stream is the stream or category that is subscribed to.
+	stream StreamIdentifier
+	// categories are the categories read by merged subscriptions, which are
+	// woken by notifications to any of them. Other subscriptions are woken
+	// by notifications to stream.Category.
+	categories []string
+	// poll reads the next batch of messages from the current position.
+	poll func(ctx context.Context) ([]*Message, error)
+	// advance moves the current position past the message and returns the
+	// new position.
+	advance func(msg *Message) int64
+	// seek moves the current position back to a handled position when the
+	// subscription is restarted.
+	seek func(position int64)
+	// tracker records the position of durable subscriptions, and is nil
+	// otherwise.
+	tracker *positionTracker
+	// workers is the number of goroutines that handle messages concurrently,
+	// messages are handled on the subscription goroutine if it is 1 or less.
+	workers int64
+	// handleBatch replaces handleMessage when set, and is passed the messages
+	// read within the batch latency.
+	handleBatch  BatchHandler
+	batchLatency time.Duration
+
+	handleMessage  MessageHandlerE
+	failurePolicy  FailurePolicy
+	handleLiveness LivenessHandler
+	handleDropped  SubDroppedHandler
+
+	// restartPolicy decides whether failed subscriptions are restarted, and is
+	// nil if they are not.
+	restartPolicy RestartPolicy
+	handleRestart RestartHandler
+
+	// handleLag is called with the subscription's lag every lagInterval, and
+	// is nil if lag is not reported.
+	handleLag   LagHandler
+	lagInterval time.Duration
+	// lagFailed receives the *PanicError if the lag handler panics.
+	lagFailed <-chan error
+
+	// liveness, polls and restarts are the state of the running
+	// subscription.
+	liveness liveness
+	polls    int
+	restarts int
+}
+
+// runSubscription polls for messages until the context is cancelled or the
+// subscription fails. Failed subscriptions with a restart policy are restarted
+// from their last handled position until the policy gives up. The
+// subscription's done channel is closed once the dropped handler has returned
+// and lag is no longer being reported.
+func (c *Client) runSubscription(ctx context.Context, sub *Subscription, loop subscriptionLoop) {
+	var reporting sync.WaitGroup
+
+	defer close(sub.done)
+	defer reporting.Wait()
+	defer sub.cancel()
+
+	if loop.handleLag != nil {
+		// panics in the lag handler stop the subscription.
+		failed := make(chan error, 1)
+		loop.lagFailed = failed
+
+		reporting.Add(1)
+		go func() {
+			defer reporting.Done()
+			reportLag(ctx, sub, loop.handleLag, loop.lagInterval, failed)
+		}()
+	}
+
+	for {
+		err := c.pollSubscription(ctx, sub, &loop)
+
+		// ignore errors once the subscription is stopped or cancelled, as
+		// drivers such as lib/pq report cancelled queries with their own
+		// errors, and ignore drained subscriptions.
+		if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, errDrained) {
+			loop.handleDropped(nil)
+			return
+		}
+
+		err = loop.stopped(sub, err)
+
+		delay, restart := time.Duration(0), false
+		if loop.restartPolicy != nil && ctx.Err() == nil && !sub.isDraining() {
+			loop.restarts++
+
+			var panicErr error
+			if delay, restart, panicErr = loop.restart(sub, err); panicErr != nil {
+				err, restart = loop.stopped(sub, panicErr), false
+			}
+		}
+
+		if !restart {
+			sub.err = err
+			loop.handleDropped(err)
+			return
+		}
+
+		// drains stop waiting, and are completed by polling again.
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			loop.handleDropped(nil)
+			return
+		case <-sub.draining:
+			timer.Stop()
+		case <-timer.C:
+		}
+
+		// resume reading after the last handled message.
+		loop.seek(sub.Position())
+	}
+}
+
+// stopped returns the *SubscriptionError for the error that stopped the
+// subscription.
+func (loop *subscriptionLoop) stopped(sub *Subscription, err error) error {
+	return &SubscriptionError{
+		Stream:   loop.stream,
+		Position: sub.Position(),
+		Attempt:  loop.polls,
+		Err:      err,
+	}
+}
+
+// restart asks the restart policy whether to restart the subscription after
+// the error, calling the restart handler if it will. Panics in either are
+// returned as a *PanicError.
+func (loop *subscriptionLoop) restart(sub *Subscription, err error) (delay time.Duration, restart bool, panicErr error) {
+	defer recoverPanic(nil, &panicErr)
+
+	if delay, restart = loop.restartPolicy(loop.restarts, err); restart && loop.handleRestart != nil {
+		loop.handleRestart(SubscriptionRestart{
+			Attempt:  loop.restarts,
+			Err:      err,
+			Delay:    delay,
+			Position: sub.Position(),
+		})
+	}
+
+	return delay, restart, nil
+}
+
+// pollSubscription polls for messages and handles them until the context is
+// cancelled or the subscription fails, returning the error that stopped it.
+// Handlers have returned by the time it returns, and their panics are
+// returned as a *PanicError.
+func (c *Client) pollSubscription(ctx context.Context, sub *Subscription, loop *subscriptionLoop) (err error) {
+	defer recoverPanic(nil, &err)
+
+	// workers handle messages concurrently, completing them between polls.
+	var (
+		pool    *workerPool
+		results <-chan workerResult
+	)
+
+	// notifications wake the subscription before its next poll is due.
+	categories := loop.categories
+	if len(categories) == 0 {
+		categories = []string{loop.stream.Category}
+	}
+
+	wake, unsubscribe := c.notifications.subscribe(categories...)
+	defer unsubscribe()
+
+	// commit moves the subscription to the position after a number of
+	// handled messages.
+	commit := func(position, messages int64) error {
+		sub.setPosition(position)
+
+		// trackers record the last handled position.
+		if loop.tracker != nil {
+			return loop.tracker.handle(ctx, position-1, messages)
+		}
+
+		return nil
+	}
+
+	if loop.workers > 1 {
+		pool = newWorkerPool(ctx, loop.workers, loop.batchSize, loop.handle, commit)
+		results = pool.results
+
+		// handlers must have returned before the subscription is dropped.
+		defer pool.stop()
+	}
+
+	// batches collect messages from polls until they are handled.
+	var (
+		batch        []*Message
+		batchNext    int64
+		batchStarted time.Time
+	)
+
+	// drain handles the messages that have been read but not handled, and
+	// records the position of durable subscriptions.
+	drain := func() error {
+		if pool != nil {
+			if err := pool.wait(ctx, 0); err != nil {
+				return err
+			}
+		}
+
+		if len(batch) > 0 {
+			if err := loop.handleBatchE(ctx, batch); err != nil {
+				return err
+			}
+
+			if err := commit(batchNext, int64(len(batch))); err != nil {
+				return err
+			}
+		}
+
+		if loop.tracker != nil {
+			if err := loop.tracker.flush(ctx); err != nil {
+				return err
+			}
+		}
+
+		return errDrained
+	}
+
+	poll := time.NewTimer(0)
+	defer poll.Stop()
+
+	for {
+		// check for context cancelled
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-sub.draining:
+			return drain()
+		case err := <-loop.lagFailed:
+			return err
+		case <-poll.C:
+		case <-wake:
+			if !poll.Stop() {
+				select {
+				case <-poll.C:
+				default:
+				}
+			}
+		case result := <-results:
+			if err := pool.complete(result); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		// drains take priority over polls that are due at the same time.
+		if sub.isDraining() {
+			continue
+		}
+
+		// limit the messages in flight to a batch before reading another.
+		if pool != nil {
+			if err := pool.wait(ctx, int(loop.batchSize)-1); err != nil {
+				return err
+			}
+		}
+
+		loop.polls++
+
+		msgs, err := loop.poll(ctx)
+		if err != nil {
+			return err
+		}
+
+		// a successful poll ends a run of restarts.
+		loop.restarts = 0
+
+		delay := loop.pollingStrat(int64(len(msgs)), loop.batchSize)
+
+		// ready is the batch to pass to the batch handler after this poll.
+		var ready []*Message
+
+		if loop.handleBatch != nil {
+			if len(batch) == 0 {
+				batchStarted = time.Now()
+			}
+
+			for _, msg := range msgs {
+				batch = append(batch, msg)
+				batchNext = loop.advance(msg)
+			}
+
+			// short batches are held until the batch latency has passed.
+			wait := loop.batchLatency - time.Since(batchStarted)
+			if len(batch) > 0 && int64(len(batch)) < loop.batchSize && wait > 0 {
+				if wait < delay {
+					delay = wait
+				}
+			} else {
+				ready, batch = batch, nil
+			}
+		}
+
+		poll.Reset(delay)
+
+		if len(ready) > 0 {
+			if err := loop.handleBatchE(ctx, ready); err != nil {
+				return err
+			}
+
+			if err := commit(batchNext, int64(len(ready))); err != nil {
+				return err
+			}
+		}
+
+		// messages are handled individually without a batch handler.
+		if loop.handleBatch == nil {
+			for _, msg := range msgs {
+				if pool != nil {
+					if err := pool.dispatch(ctx, msg, loop.advance(msg)); err != nil {
+						return err
+					}
+
+					continue
+				}
+
+				if err := loop.handle(ctx, msg); err != nil {
+					return err
+				}
+
+				if err := commit(loop.advance(msg), 1); err != nil {
+					return err
+				}
+			}
+		}
+
+		if loop.tracker != nil {
+			if err := loop.tracker.tick(ctx); err != nil {
+				return err
+			}
+		}
+
+		// if we've read fewer messages than the batch size we must have
+		// caught up and can go live. Otherwise we've fallen behind, unless
+		// the lag is within the liveness thresholds.
+		caughtUp, err := loop.liveness.caughtUp(ctx, sub, int64(len(msgs)), loop.batchSize)
+		if err != nil {
+			return err
+		}
+
+		if loop.liveness.observe(caughtUp, time.Now()) {
+			loop.handleLiveness(loop.liveness.live)
+		}
+	}
+}
+
+// handle passes the message to the handler, applying the failure policy if
+// the handler returns an error or panics.
+func (loop *subscriptionLoop) handle(ctx context.Context, msg *Message) (err error) {
+	defer recoverPanic(msg, &err)
+
+	handle := func() (err error) {
+		defer recoverPanic(msg, &err)
+
+		return loop.handleMessage(msg)
+	}
+
+	if err = handle(); err == nil {
+		return nil
+	}
+
+	return loop.failurePolicy(ctx, msg, err, handle)
+}
+
+// handleBatchE passes the batch to the batch handler. If the handler returns
+// an error or panics then each message of the batch is passed to the handler
+// on its own, and the failure policy is applied to each message that fails,
+// with retrying passing just that message to the handler again.
+func (loop *subscriptionLoop) handleBatchE(ctx context.Context, batch []*Message) (err error) {
+	defer recoverPanic(nil, &err)
+
+	batchErr := func() (err error) {
+		defer recoverPanic(nil, &err)
+
+		return loop.handleBatch(batch)
+	}()
+	if batchErr == nil {
+		return nil
+	}
+
+	for _, msg := range batch {
+		msg := msg
+		handle := func() (err error) {
+			defer recoverPanic(msg, &err)
+
+			return loop.handleBatch([]*Message{msg})
+		}
+
+		// a batch of one message has already failed on its own.
+		err := batchErr
+		if len(batch) > 1 {
+			err = handle()
+		}
+
+		if err == nil {
+			continue
+		} else if err = loop.failurePolicy(ctx, msg, err, handle); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
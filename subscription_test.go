@@ -0,0 +1,696 @@
+package gomdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testLoop returns a subscriptionLoop that reads the provided messages in
+// batches of batchSize, tracking the stream version as its position.
+func testLoop(msgs []*Message, batchSize int64) subscriptionLoop {
+	var (
+		mu       sync.Mutex
+		position int64
+	)
+
+	return subscriptionLoop{
+		batchSize:    batchSize,
+		pollingStrat: ConstantPolling(0)(),
+		poll: func(ctx context.Context) ([]*Message, error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			end := position + batchSize
+			if end > int64(len(msgs)) {
+				end = int64(len(msgs))
+			}
+
+			return msgs[position:end], nil
+		},
+		advance: func(msg *Message) int64 {
+			mu.Lock()
+			defer mu.Unlock()
+
+			position = msg.Version + 1
+			return position
+		},
+		failurePolicy:  StopOnFailure(),
+		handleLiveness: func(bool) {},
+		handleDropped:  func(error) {},
+	}
+}
+
+// writeBackend is a Backend that records the stream of every written message.
+type writeBackend struct {
+	mu      sync.Mutex
+	streams []string
+}
+
+func (b *writeBackend) Query(ctx context.Context, query string, args ...interface{}) (Rows, error) {
+	if query != WriteMessageSQL {
+		return nil, fmt.Errorf("unexpected query %q", query)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.streams = append(b.streams, args[1].(string))
+
+	return &versionRows{version: int64(len(b.streams) - 1)}, nil
+}
+
+func (b *writeBackend) BeginTx(ctx context.Context, opts *sql.TxOptions) (TxBackend, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+// versionRows returns a single row holding the version.
+type versionRows struct {
+	version int64
+	read    bool
+}
+
+func (r *versionRows) Next() bool {
+	next := !r.read
+	r.read = true
+
+	return next
+}
+
+func (r *versionRows) Scan(dest ...interface{}) error {
+	*dest[0].(*int64) = r.version
+	return nil
+}
+
+func (r *versionRows) Err() error   { return nil }
+func (r *versionRows) Close() error { return nil }
+
+func testMessages(n int) []*Message {
+	msgs := make([]*Message, n)
+	for i := range msgs {
+		msgs[i] = &Message{ID: "someID", Version: int64(i)}
+	}
+
+	return msgs
+}
+
+func Test_runSubscription_failurePolicy(t *testing.T) {
+	t.Parallel()
+
+	errHandler := errors.New("handler failed")
+
+	testcases := []struct {
+		name        string
+		policy      FailurePolicy
+		expErr      error
+		expPosition int64
+	}{
+		{
+			name:        "stop on failure",
+			policy:      StopOnFailure(),
+			expErr:      errHandler,
+			expPosition: 3,
+		},
+		{
+			name:        "skip on failure",
+			policy:      SkipOnFailure(),
+			expPosition: 10,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.TODO())
+			sub := newSubscription(cancel, 0)
+
+			loop := testLoop(testMessages(10), 4)
+			loop.failurePolicy = tc.policy
+			loop.handleMessage = func(msg *Message) error {
+				if msg.Version == 3 {
+					return errHandler
+				}
+				return nil
+			}
+			loop.handleLiveness = func(live bool) {
+				if live {
+					sub.Stop()
+				}
+			}
+
+			go (&Client{}).runSubscription(ctx, sub, loop)
+
+			if err := sub.Wait(); !errors.Is(err, tc.expErr) {
+				t.Fatalf("expected %v, actual %v", tc.expErr, err)
+			} else if sub.Position() != tc.expPosition {
+				t.Fatalf("expected position %v, actual %v", tc.expPosition, sub.Position())
+			}
+		})
+	}
+}
+
+func Test_runSubscription_positionTracker(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	sub := newSubscription(cancel, 0)
+	store := NewMemoryPositionStore()
+
+	loop := testLoop(testMessages(10), 4)
+	loop.tracker = newPositionTracker(store, 3, 0, -1)
+	loop.handleMessage = func(msg *Message) error { return nil }
+	loop.handleLiveness = func(live bool) {
+		if live {
+			sub.Stop()
+		}
+	}
+
+	go (&Client{}).runSubscription(ctx, sub, loop)
+
+	if err := sub.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	// every third message is recorded.
+	if position, _ := store.Get(context.TODO()); position != 8 {
+		t.Fatalf("expected recorded position 8, actual %v", position)
+	}
+}
+
+func Test_runSubscription_batchHandler(t *testing.T) {
+	t.Parallel()
+
+	errHandler := errors.New("handler failed")
+
+	t.Run("handles each poll", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		sub := newSubscription(cancel, 0)
+
+		var sizes []int
+
+		loop := testLoop(testMessages(10), 4)
+		loop.handleBatch = func(batch []*Message) error {
+			sizes = append(sizes, len(batch))
+			return nil
+		}
+		loop.handleLiveness = func(live bool) {
+			if live {
+				sub.Stop()
+			}
+		}
+
+		go (&Client{}).runSubscription(ctx, sub, loop)
+
+		if err := sub.Wait(); err != nil {
+			t.Fatal(err)
+		} else if len(sizes) != 3 || sizes[0] != 4 || sizes[1] != 4 || sizes[2] != 2 {
+			t.Fatalf("expected batches of 4, 4 and 2, actual %v", sizes)
+		} else if sub.Position() != 10 {
+			t.Fatalf("expected position 10, actual %v", sub.Position())
+		}
+	})
+
+	t.Run("handles failed batch one message at a time", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		sub := newSubscription(cancel, 0)
+
+		var calls []string
+
+		loop := testLoop(testMessages(10), 4)
+		loop.handleBatch = func(batch []*Message) error {
+			calls = append(calls, fmt.Sprintf("%v+%v", batch[0].Version, len(batch)))
+			if len(calls) == 2 {
+				return errHandler
+			}
+			return nil
+		}
+		loop.handleLiveness = func(live bool) {
+			if live {
+				sub.Stop()
+			}
+		}
+
+		go (&Client{}).runSubscription(ctx, sub, loop)
+
+		expected := "[0+4 4+4 4+1 5+1 6+1 7+1 8+2]"
+		if err := sub.Wait(); err != nil {
+			t.Fatal(err)
+		} else if actual := fmt.Sprint(calls); actual != expected {
+			t.Fatalf("expected calls %v, actual %v", expected, actual)
+		}
+	})
+
+	t.Run("retries failed messages on their own", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		sub := newSubscription(cancel, 0)
+		backend := &writeBackend{}
+		deadLetter := StreamIdentifier{Category: "account:deadletter", ID: "123"}
+
+		msgs := testMessages(3)
+		for _, msg := range msgs {
+			msg.Type = "SomeType"
+			msg.data = []byte(`"data"`)
+		}
+
+		var handled, poisoned int
+
+		loop := testLoop(msgs, 3)
+		loop.failurePolicy = RetryWithBackoff(2, 0, 0, 1, DeadLetterOnFailure(NewClientWithBackend(backend), deadLetter))
+		loop.handleBatch = func(batch []*Message) error {
+			// the second message always fails.
+			for _, msg := range batch {
+				if msg.Version == 1 {
+					poisoned++
+					return errHandler
+				}
+			}
+			handled += len(batch)
+			return nil
+		}
+		loop.handleLiveness = func(live bool) {
+			if live {
+				sub.Stop()
+			}
+		}
+
+		go (&Client{}).runSubscription(ctx, sub, loop)
+
+		if err := sub.Wait(); err != nil {
+			t.Fatal(err)
+		} else if handled != 2 {
+			t.Fatalf("expected 2 messages to be handled, actual %v", handled)
+		} else if poisoned != 4 {
+			t.Fatalf("expected the batch and 3 attempts of the second message to fail, actual %v", poisoned)
+		} else if len(backend.streams) != 1 {
+			t.Fatalf("expected 1 dead-letter message, actual %v", len(backend.streams))
+		}
+	})
+
+	t.Run("dead-letters every message", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		sub := newSubscription(cancel, 0)
+		backend := &writeBackend{}
+		deadLetter := StreamIdentifier{Category: "account:deadletter", ID: "123"}
+
+		msgs := testMessages(3)
+		for _, msg := range msgs {
+			msg.Type = "SomeType"
+			msg.data = []byte(`"data"`)
+		}
+
+		loop := testLoop(msgs, 3)
+		loop.failurePolicy = DeadLetterOnFailure(NewClientWithBackend(backend), deadLetter)
+		loop.handleBatch = func(batch []*Message) error {
+			return errHandler
+		}
+		loop.handleLiveness = func(live bool) {
+			if live {
+				sub.Stop()
+			}
+		}
+
+		go (&Client{}).runSubscription(ctx, sub, loop)
+
+		if err := sub.Wait(); err != nil {
+			t.Fatal(err)
+		} else if len(backend.streams) != 3 {
+			t.Fatalf("expected 3 dead-letter messages, actual %v", len(backend.streams))
+		} else if sub.Position() != 3 {
+			t.Fatalf("expected position 3, actual %v", sub.Position())
+		}
+
+		for _, stream := range backend.streams {
+			if stream != deadLetter.String() {
+				t.Fatalf("expected message written to %v, actual %v", deadLetter, stream)
+			}
+		}
+	})
+
+	t.Run("holds short batches", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			mu       sync.Mutex
+			msgs     = testMessages(3)
+			position int64
+		)
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		sub := newSubscription(cancel, 0)
+		batches := make(chan []*Message, 2)
+
+		// each poll reads one message at a time.
+		loop := testLoop(nil, 10)
+		loop.poll = func(ctx context.Context) ([]*Message, error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if position >= int64(len(msgs)) {
+				return nil, nil
+			}
+
+			return msgs[position : position+1], nil
+		}
+		loop.advance = func(msg *Message) int64 {
+			mu.Lock()
+			defer mu.Unlock()
+
+			position = msg.Version + 1
+			return position
+		}
+		loop.batchLatency = 50 * time.Millisecond
+		loop.handleBatch = func(batch []*Message) error {
+			batches <- batch
+			return nil
+		}
+
+		go (&Client{}).runSubscription(ctx, sub, loop)
+		defer sub.Stop()
+
+		select {
+		case batch := <-batches:
+			if len(batch) != 3 {
+				t.Fatalf("expected the polls to be collected into one batch, actual %v", len(batch))
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected batch to be handled after the latency")
+		}
+	})
+}
+
+func Test_runSubscription_droppedError(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	sub := newSubscription(cancel, 0)
+	errPoll := errors.New("poll failed")
+	stream := StreamIdentifier{Category: "test", ID: "123"}
+
+	var (
+		dropped error
+		polls   int
+	)
+
+	loop := testLoop(testMessages(10), 4)
+	loop.stream = stream
+	poll := loop.poll
+	loop.poll = func(ctx context.Context) ([]*Message, error) {
+		if polls++; polls == 3 {
+			return nil, errPoll
+		}
+		return poll(ctx)
+	}
+	loop.handleMessage = func(*Message) error { return nil }
+	loop.handleDropped = func(err error) {
+		dropped = err
+	}
+
+	go (&Client{}).runSubscription(ctx, sub, loop)
+
+	err := sub.Wait()
+	if !errors.Is(err, errPoll) {
+		t.Fatalf("expected %v, actual %v", errPoll, err)
+	} else if dropped != err {
+		t.Fatalf("expected dropped handler to receive %v, actual %v", err, dropped)
+	}
+
+	subErr := &SubscriptionError{}
+	if !errors.As(err, &subErr) {
+		t.Fatalf("expected a SubscriptionError, actual %T", err)
+	}
+
+	expected := SubscriptionError{Stream: stream, Position: 8, Attempt: 3, Err: errPoll}
+	if *subErr != expected {
+		t.Fatalf("expected %+v, actual %+v", expected, *subErr)
+	}
+}
+
+func Test_runSubscription_stoppedDuringPoll(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	sub := newSubscription(cancel, 0)
+	dropped := errors.New("not dropped")
+
+	loop := testLoop(nil, 4)
+	loop.poll = func(ctx context.Context) ([]*Message, error) {
+		sub.Stop()
+		// lib/pq reports cancelled queries without wrapping context.Canceled.
+		return nil, errors.New("pq: canceling statement due to user request")
+	}
+	loop.handleDropped = func(err error) {
+		dropped = err
+	}
+
+	go (&Client{}).runSubscription(ctx, sub, loop)
+
+	if err := sub.Wait(); err != nil {
+		t.Fatalf("expected no error, actual %v", err)
+	} else if dropped != nil {
+		t.Fatalf("expected dropped handler to receive nil, actual %v", dropped)
+	}
+}
+
+func Test_runSubscription_panics(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name        string
+		setup       func(loop *subscriptionLoop)
+		expErr      bool
+		expMessage  int64
+		expPosition int64
+	}{
+		{
+			name: "message handler stops",
+			setup: func(loop *subscriptionLoop) {
+				loop.handleMessage = func(msg *Message) error {
+					if msg.Version == 5 {
+						panic("poison message")
+					}
+					return nil
+				}
+			},
+			expErr:      true,
+			expMessage:  5,
+			expPosition: 5,
+		},
+		{
+			name: "message handler skipped",
+			setup: func(loop *subscriptionLoop) {
+				loop.failurePolicy = SkipOnFailure()
+				loop.handleMessage = func(msg *Message) error {
+					if msg.Version == 5 {
+						panic("poison message")
+					}
+					return nil
+				}
+			},
+			expPosition: 10,
+		},
+		{
+			name: "liveness handler",
+			setup: func(loop *subscriptionLoop) {
+				loop.handleLiveness = func(bool) {
+					panic("liveness")
+				}
+			},
+			expErr:      true,
+			expMessage:  -1,
+			expPosition: 10,
+		},
+		{
+			name: "polling strategy",
+			setup: func(loop *subscriptionLoop) {
+				loop.pollingStrat = func(retrieved, expected int64) time.Duration {
+					panic("polling")
+				}
+			},
+			expErr:      true,
+			expMessage:  -1,
+			expPosition: 0,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.TODO())
+			sub := newSubscription(cancel, 0)
+
+			loop := testLoop(testMessages(10), 4)
+			loop.handleMessage = func(*Message) error { return nil }
+			loop.handleLiveness = func(live bool) {
+				if live {
+					sub.Stop()
+				}
+			}
+			tc.setup(&loop)
+
+			go (&Client{}).runSubscription(ctx, sub, loop)
+
+			err := sub.Wait()
+			if sub.Position() != tc.expPosition {
+				t.Fatalf("expected position %v, actual %v", tc.expPosition, sub.Position())
+			} else if !tc.expErr {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			panicErr := &PanicError{}
+			if !errors.As(err, &panicErr) {
+				t.Fatalf("expected a PanicError, actual %v", err)
+			} else if len(panicErr.Stack) == 0 {
+				t.Fatal("expected the stack to be captured")
+			} else if tc.expMessage < 0 && panicErr.Message != nil {
+				t.Fatalf("expected no message, actual %v", panicErr.Message.Version)
+			} else if tc.expMessage >= 0 && (panicErr.Message == nil || panicErr.Message.Version != tc.expMessage) {
+				t.Fatalf("expected message %v, actual %+v", tc.expMessage, panicErr.Message)
+			}
+		})
+	}
+}
+
+func Test_runSubscription_drain(t *testing.T) {
+	t.Parallel()
+
+	t.Run("handles held batch and records position", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		sub := newSubscription(cancel, 0)
+		store := NewMemoryPositionStore()
+		live := make(chan struct{})
+
+		var (
+			sizes   []int
+			dropped int
+		)
+
+		loop := testLoop(testMessages(10), 4)
+		loop.tracker = newPositionTracker(store, 0, 0, -1)
+		loop.batchLatency = time.Hour
+		loop.handleBatch = func(batch []*Message) error {
+			sizes = append(sizes, len(batch))
+			return nil
+		}
+		loop.handleLiveness = func(l bool) {
+			if l {
+				close(live)
+			}
+		}
+		loop.handleDropped = func(err error) {
+			if err != nil {
+				t.Errorf("expected nil dropped error, actual %v", err)
+			}
+			dropped++
+		}
+
+		go (&Client{}).runSubscription(ctx, sub, loop)
+
+		// the last two messages are held until the batch latency has passed.
+		<-live
+
+		if err := sub.Drain(context.TODO()); err != nil {
+			t.Fatal(err)
+		} else if len(sizes) != 3 || sizes[2] != 2 {
+			t.Fatalf("expected batches of 4, 4 and 2, actual %v", sizes)
+		} else if sub.Position() != 10 {
+			t.Fatalf("expected position 10, actual %v", sub.Position())
+		} else if position, _ := store.Get(context.TODO()); position != 9 {
+			t.Fatalf("expected recorded position 9, actual %v", position)
+		} else if dropped != 1 {
+			t.Fatalf("expected dropped handler to be called once, actual %v", dropped)
+		}
+	})
+
+	t.Run("waits for workers", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		sub := newSubscription(cancel, 0)
+		store := NewMemoryPositionStore()
+		started := make(chan struct{}, 10)
+		release := make(chan struct{})
+
+		loop := testLoop(testStreamMessages(10, 3), 10)
+		loop.workers = 3
+		loop.tracker = newPositionTracker(store, 0, 0, -1)
+		loop.handleMessage = func(msg *Message) error {
+			started <- struct{}{}
+			<-release
+			return nil
+		}
+
+		go (&Client{}).runSubscription(ctx, sub, loop)
+
+		<-started
+
+		drained := make(chan error)
+		go func() {
+			drained <- sub.Drain(context.TODO())
+		}()
+
+		select {
+		case err := <-drained:
+			t.Fatalf("expected drain to wait for handlers, returned %v", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		close(release)
+
+		if err := <-drained; err != nil {
+			t.Fatal(err)
+		} else if sub.Position() != 10 {
+			t.Fatalf("expected position 10, actual %v", sub.Position())
+		} else if position, _ := store.Get(context.TODO()); position != 9 {
+			t.Fatalf("expected recorded position 9, actual %v", position)
+		}
+	})
+
+	t.Run("stops when context ends", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		sub := newSubscription(cancel, 0)
+		started := make(chan struct{})
+
+		loop := testLoop(testMessages(10), 4)
+		loop.handleMessage = func(msg *Message) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}
+
+		go (&Client{}).runSubscription(ctx, sub, loop)
+
+		<-started
+
+		drainCtx, drainCancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+		defer drainCancel()
+
+		if err := sub.Drain(drainCtx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected %v, actual %v", context.DeadlineExceeded, err)
+		} else if err := sub.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alexrudd/gomdb"
+)
+
+// BenchmarkGetStreamMessages compares reading a stream through the Client's
+// cached prepared statement against preparing the statement for every call.
+func BenchmarkGetStreamMessages(b *testing.B) {
+	db := OpenDB(b)
+	client := gomdb.NewClient(db)
+	defer client.Close()
+
+	stream := NewTestStream(NewTestCategory("bench"))
+	PopulateStream(b, client, stream, 10)
+
+	b.Run("cached statement", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := client.GetStreamMessages(context.TODO(), stream); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("prepare per call", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			stmt, err := db.PrepareContext(context.TODO(), gomdb.GetStreamMessagesSQL)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			rows, err := stmt.QueryContext(context.TODO(), stream.String(), 0, 1000, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			for rows.Next() {
+			}
+
+			rows.Close()
+			stmt.Close()
+		}
+	})
+}
+
+// BenchmarkGetStreamVersion compares reading a stream version through the
+// Client's cached prepared statement against preparing the statement for
+// every call.
+func BenchmarkGetStreamVersion(b *testing.B) {
+	db := OpenDB(b)
+	client := gomdb.NewClient(db)
+	defer client.Close()
+
+	stream := NewTestStream(NewTestCategory("bench"))
+	PopulateStream(b, client, stream, 1)
+
+	b.Run("cached statement", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := client.GetStreamVersion(context.TODO(), stream); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("prepare per call", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			stmt, err := db.PrepareContext(context.TODO(), gomdb.GetStreamVersionSQL)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			var version interface{}
+			if err = stmt.QueryRowContext(context.TODO(), stream.String()).Scan(&version); err != nil {
+				b.Fatal(err)
+			}
+
+			stmt.Close()
+		}
+	})
+}
+
+// BenchmarkWriteMessage compares writing through the Client's cached prepared
+// statement against preparing the statement for every call.
+func BenchmarkWriteMessage(b *testing.B) {
+	db := OpenDB(b)
+	client := gomdb.NewClient(db)
+	defer client.Close()
+
+	b.Run("cached statement", func(b *testing.B) {
+		stream := NewTestStream(NewTestCategory("bench"))
+		for i := 0; i < b.N; i++ {
+			_, err := client.WriteMessage(context.TODO(), stream, gomdb.ProposedMessage{
+				ID:   GenUUID(),
+				Type: "TestMessage",
+				Data: "data",
+			}, gomdb.AnyVersion)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("prepare per call", func(b *testing.B) {
+		stream := NewTestStream(NewTestCategory("bench"))
+		for i := 0; i < b.N; i++ {
+			stmt, err := db.PrepareContext(context.TODO(), gomdb.WriteMessageSQL)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			var version int64
+			err = stmt.QueryRowContext(context.TODO(), GenUUID(), stream.String(), "TestMessage", []byte(`"data"`), []byte("null"), nil).Scan(&version)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			stmt.Close()
+		}
+	})
+}
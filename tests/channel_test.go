@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexrudd/gomdb"
+)
+
+// TestSubscribeToCategoryChan tests receiving category messages from a
+// channel.
+func TestSubscribeToCategoryChan(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+	category := PopulateCategory(t, client, NewTestCategory("chan"), 3, 4)
+
+	sub, err := client.SubscribeToCategoryChan(context.TODO(), category,
+		gomdb.WithCategoryBatchSize(5),
+		gomdb.WithCategoryChannelBuffer(2),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the subscription waits for the consumer once the buffer is full.
+	time.Sleep(100 * time.Millisecond)
+
+	if sub.Position() == 0 {
+		t.Fatal("expected subscription to have buffered messages")
+	}
+
+	position := sub.Position()
+	time.Sleep(100 * time.Millisecond)
+
+	if sub.Position() != position {
+		t.Fatalf("expected subscription to wait at %v while the buffer is full, actual %v", position, sub.Position())
+	}
+
+	last := int64(-1)
+	for i := 0; i < 12; i++ {
+		msg := <-sub.Messages()
+		if msg.GlobalPosition <= last {
+			t.Fatalf("expected messages in order, received %v after %v", msg.GlobalPosition, last)
+		}
+		last = msg.GlobalPosition
+	}
+
+	if event := <-sub.Events(); event != (gomdb.LivenessChanged{Live: true}) {
+		t.Fatalf("expected to go live, actual %#v", event)
+	}
+
+	sub.Stop()
+
+	for event := range sub.Events() {
+		if dropped, ok := event.(gomdb.SubscriptionDropped); ok && dropped.Err != nil {
+			t.Fatalf("expected nil error on stop, actual: %s", dropped.Err)
+		}
+	}
+
+	if err := sub.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSubscribeToStreamChan tests receiving stream messages from a channel.
+func TestSubscribeToStreamChan(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+	stream := NewTestStream(NewTestCategory("chan"))
+	PopulateStream(t, client, stream, 3)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	sub, err := client.SubscribeToStreamChan(ctx, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := int64(0); i < 3; i++ {
+		if msg := <-sub.Messages(); msg.Version != i {
+			t.Fatalf("expected version %v, actual %v", i, msg.Version)
+		}
+	}
+
+	cancel()
+
+	// ranging ends once the subscription has exited.
+	for range sub.Messages() {
+	}
+
+	if err := sub.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
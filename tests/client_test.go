@@ -9,7 +9,9 @@ import (
 	"testing"
 
 	"github.com/alexrudd/gomdb"
+	"github.com/alexrudd/gomdb/pgxmdb"
 	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/lib/pq"
 )
 
@@ -21,16 +23,49 @@ var (
 	password      = flag.String("password", "", "the password to use to login")
 	sslmode       = flag.String("sslmode", "disable", "the ssl mode to connect with")
 	isConditionOn = flag.Bool("condition-on", false, "is the SQL condition feature on")
+	backend       = flag.String("backend", "sql", "the client backend to test (sql or pgx)")
 )
 
 func Init() {
 	flag.Parse()
 }
 
-// NewClient opens a new DB connection then creates and returns a Client.
-func NewClient(t *testing.T) *gomdb.Client {
+// NewClient opens a new DB connection using the selected backend then creates
+// and returns a Client.
+func NewClient(t testing.TB) *gomdb.Client {
 	t.Helper()
 
+	switch *backend {
+	case "sql":
+		return gomdb.NewClient(OpenDB(t))
+	case "pgx":
+		return pgxmdb.NewClient(OpenPool(t))
+	}
+
+	t.Fatalf("unknown backend: %s", *backend)
+
+	return nil
+}
+
+// NewBackend opens a new DB connection using the selected backend then creates
+// and returns its Backend.
+func NewBackend(t testing.TB) gomdb.Backend {
+	t.Helper()
+
+	switch *backend {
+	case "sql":
+		return gomdb.NewSQLBackend(OpenDB(t))
+	case "pgx":
+		return pgxmdb.NewBackend(OpenPool(t))
+	}
+
+	t.Fatalf("unknown backend: %s", *backend)
+
+	return nil
+}
+
+// connString returns the connection string for the test DB.
+func connString() string {
 	conn := fmt.Sprintf("host=%s port=%v dbname=%s user=%s sslmode=%s",
 		*host, *port, *dbname, *user, *sslmode)
 
@@ -38,6 +73,37 @@ func NewClient(t *testing.T) *gomdb.Client {
 		conn += " password=" + *password
 	}
 
+	return conn
+}
+
+// OpenPool opens a new pgx connection pool that is closed when the test
+// completes.
+func OpenPool(t testing.TB) *pgxpool.Pool {
+	t.Helper()
+
+	cfg, err := pgxpool.ParseConfig(connString())
+	if err != nil {
+		t.Fatalf("parsing pool config: %s", err)
+	}
+
+	cfg.ConnConfig.RuntimeParams["search_path"] = "message_store,public"
+
+	pool, err := pgxpool.NewWithConfig(context.TODO(), cfg)
+	if err != nil {
+		t.Fatalf("opening pool: %s", err)
+	}
+
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
+// OpenDB opens a new DB connection that is closed when the test completes.
+func OpenDB(t testing.TB) *sql.DB {
+	t.Helper()
+
+	conn := connString()
+
 	db, err := sql.Open("postgres", conn)
 	if err != nil {
 		t.Fatalf("opening db (%s): %s", conn, err)
@@ -52,7 +118,7 @@ func NewClient(t *testing.T) *gomdb.Client {
 		t.Fatalf("setting search path: %s", err)
 	}
 
-	return gomdb.NewClient(db)
+	return db
 }
 
 // GenUUID returns a unique UUID.
@@ -76,7 +142,7 @@ func NewTestCategory(prefix string) string {
 
 // PopulateStream creates the specified number of messages and writes them
 // to the specified stream.
-func PopulateStream(t *testing.T, client *gomdb.Client, stream gomdb.StreamIdentifier, messages int) {
+func PopulateStream(t testing.TB, client *gomdb.Client, stream gomdb.StreamIdentifier, messages int) {
 	t.Helper()
 
 	var (
@@ -98,7 +164,7 @@ func PopulateStream(t *testing.T, client *gomdb.Client, stream gomdb.StreamIdent
 
 // PopulateCategory creates multiple streams within a single categatory and
 // populates them with messages. The actual category is returned.
-func PopulateCategory(t *testing.T, client *gomdb.Client, category string, streams, messages int) string {
+func PopulateCategory(t testing.TB, client *gomdb.Client, category string, streams, messages int) string {
 	t.Helper()
 
 	for i := 0; i < streams; i++ {
@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alexrudd/gomdb"
+)
+
+// TestHash64 tests that Hash64 matches the server's hash_64 function.
+func TestHash64(t *testing.T) {
+	t.Parallel()
+
+	db := OpenDB(t)
+
+	for i := 0; i < 500; i++ {
+		value := GenUUID()
+
+		var expected int64
+		err := db.QueryRowContext(context.TODO(), "SELECT hash_64($1)", value).Scan(&expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual := gomdb.Hash64(value); actual != expected {
+			t.Fatalf("hash of %s: expected %v, actual %v", value, expected, actual)
+		}
+	}
+}
+
+// TestConsumerGroupMemberFor tests that ConsumerGroupMemberFor predicts which
+// consumer group member reads each stream.
+func TestConsumerGroupMemberFor(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+	category := NewTestCategory("group")
+
+	for i := 0; i < 50; i++ {
+		stream := gomdb.NewStreamIdentifier(category, nil, GenUUID())
+		if i%5 == 0 {
+			// compound IDs are assigned by their cardinal ID.
+			stream = gomdb.NewStreamIdentifier(category, nil, GenUUID(), GenUUID())
+		}
+
+		PopulateStream(t, client, stream, 1)
+	}
+
+	for _, size := range []int64{1, 2, 3, 7} {
+		read := 0
+		for member := int64(0); member < size; member++ {
+			msgs, err := client.GetCategoryMessages(context.TODO(), category, gomdb.AsConsumerGroup(member, size))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			read += len(msgs)
+			for _, msg := range msgs {
+				if predicted := gomdb.ConsumerGroupMemberFor(msg.Stream, size); predicted != member {
+					t.Fatalf("stream %s read by member %v/%v, predicted %v", msg.Stream, member, size, predicted)
+				}
+			}
+		}
+
+		if read != 50 {
+			t.Fatalf("expected 50 messages across group of %v, got %v", size, read)
+		}
+	}
+}
@@ -1,11 +1,26 @@
 module github.com/alexrudd/gomdb/tests
 
-go 1.16
+go 1.19
 
 require (
 	github.com/alexrudd/gomdb v0.0.0-local
+	github.com/alexrudd/gomdb/pgxmdb v0.0.0-local
 	github.com/gofrs/uuid v1.2.0
+	github.com/jackc/pgx/v5 v5.5.5
 	github.com/lib/pq v1.10.2
 )
 
-replace github.com/alexrudd/gomdb v0.0.0-local => ../
\ No newline at end of file
+require (
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
+	github.com/jackc/puddle/v2 v2.2.1 // indirect
+	github.com/kr/text v0.2.0 // indirect
+	golang.org/x/crypto v0.17.0 // indirect
+	golang.org/x/sync v0.1.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+)
+
+replace (
+	github.com/alexrudd/gomdb v0.0.0-local => ../
+	github.com/alexrudd/gomdb/pgxmdb v0.0.0-local => ../pgxmdb
+)
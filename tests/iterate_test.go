@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alexrudd/gomdb"
+)
+
+// TestIterateStream tests the IterateStream API.
+func TestIterateStream(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+
+	t.Run("stream does not exist", func(t *testing.T) {
+		t.Parallel()
+
+		it := client.IterateStream(context.TODO(), NewTestStream(NewTestCategory("nonexistant")))
+		if it.Next() {
+			t.Fatal("expected no messages")
+		} else if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("iterate entire stream in batches", func(t *testing.T) {
+		t.Parallel()
+
+		stream := NewTestStream(NewTestCategory("entire"))
+		PopulateStream(t, client, stream, 12)
+
+		it := client.IterateStream(context.TODO(), stream, gomdb.WithStreamBatchSize(5))
+
+		version := int64(0)
+		for it.Next() {
+			if it.Message().Version != version {
+				t.Fatalf("expected message with version %v, got %v", version, it.Message().Version)
+			}
+			version++
+		}
+
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		} else if version != 12 {
+			t.Fatalf("expected 12 messages, got %v", version)
+		}
+	})
+
+	t.Run("iterate from version", func(t *testing.T) {
+		t.Parallel()
+
+		stream := NewTestStream(NewTestCategory("from"))
+		PopulateStream(t, client, stream, 10)
+
+		it := client.IterateStream(context.TODO(), stream, gomdb.WithStreamBatchSize(3), gomdb.FromVersion(4))
+
+		count := 0
+		for it.Next() {
+			count++
+		}
+
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		} else if count != 6 {
+			t.Fatalf("expected 6 messages, got %v", count)
+		}
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		t.Parallel()
+
+		it := client.IterateStream(context.TODO(), NewTestStream(NewTestCategory("invalid")), gomdb.WithStreamBatchSize(0))
+		if it.Next() {
+			t.Fatal("expected no messages")
+		} else if it.Err() == nil {
+			t.Fatal("expected validation error")
+		}
+	})
+}
+
+// TestIterateCategory tests the IterateCategory API.
+func TestIterateCategory(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+
+	t.Run("iterate entire category in batches", func(t *testing.T) {
+		t.Parallel()
+
+		category := PopulateCategory(t, client, NewTestCategory("entire"), 3, 7)
+
+		it := client.IterateCategory(context.TODO(), category, gomdb.WithCategoryBatchSize(4))
+
+		count := 0
+		position := int64(0)
+		for it.Next() {
+			if it.Message().GlobalPosition <= position {
+				t.Fatalf("expected increasing global positions, got %v after %v", it.Message().GlobalPosition, position)
+			}
+			position = it.Message().GlobalPosition
+			count++
+		}
+
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		} else if count != 21 {
+			t.Fatalf("expected 21 messages, got %v", count)
+		}
+	})
+
+	t.Run("iterate as consumer group", func(t *testing.T) {
+		t.Parallel()
+
+		category := PopulateCategory(t, client, NewTestCategory("group"), 6, 5)
+
+		count := 0
+		for member := int64(0); member < 2; member++ {
+			it := client.IterateCategory(context.TODO(), category,
+				gomdb.WithCategoryBatchSize(4),
+				gomdb.AsConsumerGroup(member, 2),
+			)
+
+			for it.Next() {
+				count++
+			}
+
+			if err := it.Err(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if count != 30 {
+			t.Fatalf("expected 30 messages across consumer group, got %v", count)
+		}
+	})
+}
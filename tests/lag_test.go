@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexrudd/gomdb"
+)
+
+func TestGetHeadPosition(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+
+	t.Run("category does not exist", func(t *testing.T) {
+		t.Parallel()
+
+		position, err := client.GetCategoryHeadPosition(context.TODO(), NewTestCategory("nonexistant"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if position != -1 {
+			t.Fatalf("expected position -1, actual %v", position)
+		}
+	})
+
+	t.Run("get category head position", func(t *testing.T) {
+		t.Parallel()
+
+		category := PopulateCategory(t, client, NewTestCategory("head"), 2, 3)
+
+		msgs, err := client.GetCategoryMessages(context.TODO(), category)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		position, err := client.GetCategoryHeadPosition(context.TODO(), category)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if expected := msgs[len(msgs)-1].GlobalPosition; position != expected {
+			t.Fatalf("expected position %v, actual %v", expected, position)
+		}
+
+		// the store's head is at or after the category's.
+		head, err := client.GetHeadPosition(context.TODO())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if head < position {
+			t.Fatalf("expected head position of at least %v, actual %v", position, head)
+		}
+	})
+}
+
+func TestSubscriptionLag(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+
+	t.Run("stream lag", func(t *testing.T) {
+		t.Parallel()
+
+		stream := NewTestStream(NewTestCategory("lag"))
+		PopulateStream(t, client, stream, 5)
+
+		live := make(chan struct{})
+		sub, err := client.SubscribeToStream(
+			context.TODO(),
+			stream,
+			func(m *gomdb.Message) {},
+			func(l bool) {
+				if l {
+					close(live)
+				}
+			},
+			func(error) {},
+			gomdb.FromVersion(2),
+			gomdb.WithStreamBatchSize(10),
+			gomdb.WithStreamPollingStrategy(gomdb.ConstantPolling(time.Hour)()),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sub.Stop()
+
+		<-live
+
+		lag, err := sub.Lag(context.TODO())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if lag != (gomdb.Lag{Head: 4, Position: 4}) {
+			t.Fatalf("expected no lag, actual %+v", lag)
+		}
+
+		// messages written while the subscription waits to poll are behind.
+		for i := 0; i < 2; i++ {
+			if _, err := client.WriteMessage(context.TODO(), stream, gomdb.ProposedMessage{
+				ID:   GenUUID(),
+				Type: "TestMessage",
+				Data: "data",
+			}, gomdb.AnyVersion); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		lag, err = sub.Lag(context.TODO())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if lag.Head != 6 || lag.Position != 4 || lag.Messages != 2 || lag.Time <= 0 {
+			t.Fatalf("expected 2 messages of lag, actual %+v", lag)
+		}
+	})
+
+	t.Run("category lag handler", func(t *testing.T) {
+		t.Parallel()
+
+		category := PopulateCategory(t, client, NewTestCategory("lag"), 4, 3)
+
+		reported := make(chan gomdb.Lag, 1)
+		sub, err := client.SubscribeToCategory(
+			context.TODO(),
+			category,
+			func(m *gomdb.Message) {},
+			func(bool) {},
+			func(error) {},
+			gomdb.AsConsumerGroup(0, 2),
+			gomdb.WithCategoryLagHandler(func(lag gomdb.Lag, err error) {
+				if err != nil {
+					t.Error(err)
+				}
+
+				select {
+				case reported <- lag:
+				default:
+				}
+			}, 10*time.Millisecond),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sub.Stop()
+
+		head, err := client.GetCategoryHeadPosition(context.TODO(), category)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the subscription catches up with the category.
+		deadline := time.After(5 * time.Second)
+		for {
+			select {
+			case lag := <-reported:
+				if lag.Head != head {
+					t.Fatalf("expected head %v, actual %v", head, lag.Head)
+				} else if lag.Messages == 0 && lag.Time == 0 {
+					return
+				}
+			case <-deadline:
+				t.Fatal("timed out waiting for the subscription to catch up")
+			}
+		}
+	})
+}
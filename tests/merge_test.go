@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/alexrudd/gomdb"
+)
+
+// TestSubscribeToCategories tests merging several categories in global
+// position order.
+func TestSubscribeToCategories(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+	order := NewTestCategory("order")
+	payment := NewTestCategory("payment")
+
+	// interleave the categories so that batches have to be merged.
+	for i := 0; i < 3; i++ {
+		PopulateCategory(t, client, order, 1, 2)
+		PopulateCategory(t, client, payment, 1, 3)
+	}
+
+	t.Run("merges in global position order", func(t *testing.T) {
+		t.Parallel()
+
+		var received []*gomdb.Message
+		goneLive := make(chan struct{})
+
+		sub, err := client.SubscribeToCategories(
+			context.TODO(),
+			[]string{order, payment},
+			func(m *gomdb.Message) {
+				received = append(received, m)
+			},
+			func(live bool) {
+				if live {
+					close(goneLive)
+				}
+			},
+			func(err error) {
+				if err != nil {
+					t.Errorf("received subscription error: %s", err)
+				}
+			},
+			gomdb.WithCategoryBatchSize(4),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		<-goneLive
+		sub.Stop()
+
+		if err := sub.Wait(); err != nil {
+			t.Fatal(err)
+		} else if len(received) != 15 {
+			t.Fatalf("expected 15 messages, received %v", len(received))
+		}
+
+		for i := 1; i < len(received); i++ {
+			if received[i].GlobalPosition <= received[i-1].GlobalPosition {
+				t.Fatalf("expected messages in global position order, %v received after %v",
+					received[i].GlobalPosition, received[i-1].GlobalPosition)
+			}
+		}
+
+		if expected := received[len(received)-1].GlobalPosition + 1; sub.Position() != expected {
+			t.Fatalf("expected position %v, actual %v", expected, sub.Position())
+		}
+	})
+
+	t.Run("consumer group", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			mu       sync.Mutex
+			received = map[gomdb.StreamIdentifier]int64{}
+			wg       sync.WaitGroup
+		)
+
+		wg.Add(2)
+
+		for member := int64(0); member < 2; member++ {
+			member := member
+			goneLive := make(chan struct{})
+
+			sub, err := client.SubscribeToCategories(
+				context.TODO(),
+				[]string{order, payment},
+				func(m *gomdb.Message) {
+					if assigned := gomdb.ConsumerGroupMemberFor(m.Stream, 2); assigned != member {
+						t.Errorf("member %v received message for member %v", member, assigned)
+					}
+
+					mu.Lock()
+					defer mu.Unlock()
+					received[m.Stream]++
+				},
+				func(live bool) {
+					if live {
+						close(goneLive)
+					}
+				},
+				func(err error) {
+					if err != nil {
+						t.Errorf("received subscription error: %s", err)
+					}
+				},
+				gomdb.AsConsumerGroup(member, 2),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			go func() {
+				defer wg.Done()
+
+				<-goneLive
+				sub.Stop()
+				_ = sub.Wait()
+			}()
+		}
+
+		wg.Wait()
+
+		var total int64
+		for _, n := range received {
+			total += n
+		}
+
+		if total != 15 {
+			t.Fatalf("expected the group to receive 15 messages, received %v", total)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexrudd/gomdb"
+	"github.com/alexrudd/gomdb/pgxmdb"
+)
+
+// listeningNotifier wraps a Notifier, closing listening once it receives a
+// notification for the probe category. Probe notifications are not passed on.
+type listeningNotifier struct {
+	gomdb.Notifier
+	probe     string
+	listening chan struct{}
+	once      sync.Once
+}
+
+func (n *listeningNotifier) Listen(ctx context.Context, notify func(category string)) error {
+	return n.Notifier.Listen(ctx, func(category string) {
+		if category == n.probe {
+			n.once.Do(func() { close(n.listening) })
+			return
+		}
+
+		notify(category)
+	})
+}
+
+// TestNotifier tests that subscriptions are woken by notifications rather
+// than waiting for their next poll.
+func TestNotifier(t *testing.T) {
+	t.Parallel()
+
+	pool := OpenPool(t)
+	if err := pgxmdb.InstallNotifyTrigger(context.TODO(), pool, gomdb.DefaultNotifyChannel); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := pgxmdb.UninstallNotifyTrigger(context.TODO(), pool); err != nil {
+			t.Error(err)
+		}
+	})
+
+	notifier := &listeningNotifier{
+		Notifier:  pgxmdb.NewNotifier(pool, gomdb.DefaultNotifyChannel),
+		probe:     NewTestCategory("probe"),
+		listening: make(chan struct{}),
+	}
+
+	client := pgxmdb.NewClient(pool,
+		gomdb.WithNotifier(notifier),
+		// polling would take too long for the test to pass.
+		gomdb.WithDefaultPollingStrategy(gomdb.ConstantPolling(time.Hour)),
+	)
+
+	stream := NewTestStream(NewTestCategory("notify"))
+	received := make(chan *gomdb.Message)
+	goneLive := make(chan struct{})
+
+	sub, err := client.SubscribeToStream(
+		context.TODO(),
+		stream,
+		func(m *gomdb.Message) {
+			received <- m
+		},
+		func(live bool) {
+			if live {
+				close(goneLive)
+			}
+		},
+		func(err error) {
+			if err != nil {
+				t.Errorf("received subscription error: %s", err)
+			}
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Stop()
+
+	<-goneLive
+
+	// notifications sent before the notifier starts listening are missed, so
+	// probe until one is received.
+	probe := time.NewTicker(10 * time.Millisecond)
+	defer probe.Stop()
+
+	timeout := time.After(5 * time.Second)
+
+	for listening := false; !listening; {
+		if _, err := pool.Exec(context.TODO(), "SELECT pg_notify($1, $2)", gomdb.DefaultNotifyChannel, notifier.probe); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case <-notifier.listening:
+			listening = true
+		case <-probe.C:
+		case <-timeout:
+			t.Fatal("expected notifier to start listening")
+		}
+	}
+
+	PopulateStream(t, client, stream, 1)
+
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected subscription to be woken by notification")
+	}
+}
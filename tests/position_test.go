@@ -0,0 +1,236 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alexrudd/gomdb"
+)
+
+// TestConsumerPosition tests that durable category consumers record their
+// position and resume from it.
+func TestConsumerPosition(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+	category := PopulateCategory(t, client, NewTestCategory("consumer"), 2, 3)
+	consumerID := GenUUID()
+
+	// subscribe runs a durable subscription until it goes live, returning the
+	// messages received.
+	subscribe := func() ([]*gomdb.Message, *gomdb.Subscription) {
+		var received []*gomdb.Message
+		goneLive := make(chan struct{})
+
+		sub, err := client.SubscribeToCategory(
+			context.TODO(),
+			category,
+			func(m *gomdb.Message) {
+				received = append(received, m)
+			},
+			func(live bool) {
+				if live {
+					close(goneLive)
+				}
+			},
+			func(err error) {
+				if err != nil {
+					t.Errorf("received subscription error: %s", err)
+				}
+			},
+			gomdb.WithConsumerID(consumerID),
+			gomdb.WithCategoryPositionUpdateInterval(1, 0),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		<-goneLive
+		sub.Stop()
+
+		if err := sub.Wait(); err != nil {
+			t.Fatal(err)
+		}
+
+		return received, sub
+	}
+
+	first, sub := subscribe()
+	if len(first) != 6 {
+		t.Fatalf("expected 6 messages, received %v", len(first))
+	}
+
+	msg, err := client.GetLastStreamMessage(context.TODO(), gomdb.PositionStream(category, consumerID))
+	if err != nil {
+		t.Fatal(err)
+	} else if msg == nil {
+		t.Fatal("expected position to be recorded")
+	}
+
+	recorded := gomdb.PositionRecorded{}
+	if err := msg.UnmarshalData(&recorded); err != nil {
+		t.Fatal(err)
+	} else if recorded.Position != sub.Position()-1 {
+		t.Fatalf("expected recorded position %v, actual %v", sub.Position()-1, recorded.Position)
+	}
+
+	PopulateCategory(t, client, category, 1, 2)
+
+	second, _ := subscribe()
+	if len(second) != 2 {
+		t.Fatalf("expected to resume and receive 2 new messages, received %v", len(second))
+	}
+}
+
+// TestDrainPosition tests that draining a durable subscription records its
+// position.
+func TestDrainPosition(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+	category := PopulateCategory(t, client, NewTestCategory("drain"), 2, 3)
+	consumerID := GenUUID()
+	goneLive := make(chan struct{})
+
+	sub, err := client.SubscribeToCategory(
+		context.TODO(),
+		category,
+		func(m *gomdb.Message) {},
+		func(live bool) {
+			if live {
+				close(goneLive)
+			}
+		},
+		func(err error) {
+			if err != nil {
+				t.Errorf("received subscription error: %s", err)
+			}
+		},
+		gomdb.WithConsumerID(consumerID),
+		// positions are only recorded when the subscription is drained.
+		gomdb.WithCategoryPositionUpdateInterval(0, 0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-goneLive
+
+	if err := sub.Drain(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := client.GetLastStreamMessage(context.TODO(), gomdb.PositionStream(category, consumerID))
+	if err != nil {
+		t.Fatal(err)
+	} else if msg == nil {
+		t.Fatal("expected position to be recorded")
+	}
+
+	recorded := gomdb.PositionRecorded{}
+	if err := msg.UnmarshalData(&recorded); err != nil {
+		t.Fatal(err)
+	} else if recorded.Position != sub.Position()-1 {
+		t.Fatalf("expected recorded position %v, actual %v", sub.Position()-1, recorded.Position)
+	}
+}
+
+// TestPositionStores tests resuming stream subscriptions from each of the
+// PositionStore implementations.
+func TestPositionStores(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+	db := OpenDB(t)
+
+	sqlStore := gomdb.NewSQLPositionStore(db, "gomdb_test_positions", GenUUID())
+	if err := sqlStore.CreateTable(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		name  string
+		store gomdb.PositionStore
+	}{
+		{
+			name:  "message db",
+			store: gomdb.NewMessageDBPositionStore(client, gomdb.PositionStream(NewTestCategory("store"), GenUUID())),
+		},
+		{
+			name:  "sql",
+			store: sqlStore,
+		},
+		{
+			name:  "memory",
+			store: gomdb.NewMemoryPositionStore(),
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			stream := NewTestStream(NewTestCategory("store"))
+			PopulateStream(t, client, stream, 5)
+
+			// subscribe runs a subscription until it goes live, returning the
+			// number of messages received.
+			subscribe := func() int {
+				received := 0
+				goneLive := make(chan struct{})
+
+				sub, err := client.SubscribeToStream(
+					context.TODO(),
+					stream,
+					func(m *gomdb.Message) {
+						received++
+					},
+					func(live bool) {
+						if live {
+							close(goneLive)
+						}
+					},
+					func(err error) {},
+					gomdb.WithStreamPositionStore(tc.store),
+					gomdb.WithStreamPositionUpdateInterval(1, 0),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				<-goneLive
+				sub.Stop()
+
+				if err := sub.Wait(); err != nil {
+					t.Fatal(err)
+				}
+
+				return received
+			}
+
+			if received := subscribe(); received != 5 {
+				t.Fatalf("expected 5 messages, received %v", received)
+			}
+
+			if position, err := tc.store.Get(context.TODO()); err != nil {
+				t.Fatal(err)
+			} else if position != 4 {
+				t.Fatalf("expected recorded version 4, actual %v", position)
+			}
+
+			_, err := client.WriteMessage(context.TODO(), stream, gomdb.ProposedMessage{
+				ID:   GenUUID(),
+				Type: "TestMessage",
+				Data: "data",
+			}, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if received := subscribe(); received != 1 {
+				t.Fatalf("expected to resume and receive 1 new message, received %v", received)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package tests
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/alexrudd/gomdb"
@@ -117,6 +118,98 @@ func TestGetStreamMessages(t *testing.T) {
 	})
 }
 
+// TestReadAllStreamMessages tests the ReadAllStreamMessages API.
+func TestReadAllStreamMessages(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+
+	t.Run("stream does not exist", func(t *testing.T) {
+		t.Parallel()
+
+		stream := NewTestStream(NewTestCategory("nonexistant"))
+
+		msgs, err := client.ReadAllStreamMessages(context.TODO(), stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(msgs) != 0 {
+			t.Fatalf("expected no messages, got %v", len(msgs))
+		}
+	})
+
+	t.Run("read beyond batch size", func(t *testing.T) {
+		t.Parallel()
+
+		stream := NewTestStream(NewTestCategory("entire"))
+		PopulateStream(t, client, stream, 12)
+
+		msgs, err := client.ReadAllStreamMessages(context.TODO(), stream, gomdb.WithStreamBatchSize(5))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(msgs) != 12 {
+			t.Fatalf("expected 12 messages, got %v", len(msgs))
+		}
+
+		for ver, msg := range msgs {
+			if msg.Version != int64(ver) {
+				t.Fatalf("expected message with version %v, got %v", ver, msg.Version)
+			}
+		}
+	})
+
+	t.Run("read from version", func(t *testing.T) {
+		t.Parallel()
+
+		stream := NewTestStream(NewTestCategory("from"))
+		PopulateStream(t, client, stream, 12)
+
+		msgs, err := client.ReadAllStreamMessages(context.TODO(), stream, gomdb.WithStreamBatchSize(5), gomdb.FromVersion(4))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(msgs) != 8 {
+			t.Fatalf("expected 8 messages, got %v", len(msgs))
+		}
+	})
+
+	t.Run("read up to message limit", func(t *testing.T) {
+		t.Parallel()
+
+		stream := NewTestStream(NewTestCategory("limit"))
+		PopulateStream(t, client, stream, 10)
+
+		msgs, err := client.ReadAllStreamMessages(context.TODO(), stream, gomdb.WithStreamMessageLimit(10))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(msgs) != 10 {
+			t.Fatalf("expected 10 messages, got %v", len(msgs))
+		}
+	})
+
+	t.Run("exceed message limit", func(t *testing.T) {
+		t.Parallel()
+
+		stream := NewTestStream(NewTestCategory("limit"))
+		PopulateStream(t, client, stream, 10)
+
+		_, err := client.ReadAllStreamMessages(context.TODO(), stream, gomdb.WithStreamMessageLimit(9), gomdb.WithStreamBatchSize(4))
+
+		var limitErr *gomdb.MessageLimitError
+		if !errors.As(err, &limitErr) {
+			t.Fatalf("expected message limit error, actual: %v", err)
+		} else if limitErr.Limit != 9 {
+			t.Fatalf("expected limit 9, actual %v", limitErr.Limit)
+		}
+	})
+}
+
 // TestGetCategoryMessages tests the GetCategoryMessages API.
 func TestGetCategoryMessages(t *testing.T) {
 	t.Parallel()
@@ -210,6 +303,61 @@ func TestGetCategoryMessages(t *testing.T) {
 		}
 	})
 
+	t.Run("read message stream identifiers", func(t *testing.T) {
+		t.Parallel()
+
+		category := NewTestCategory("identified")
+		streams := map[gomdb.StreamIdentifier]bool{
+			{Category: category, ID: GenUUID()}:                   true,
+			{Category: category, ID: GenUUID() + "+" + GenUUID()}: true,
+		}
+
+		for stream := range streams {
+			PopulateStream(t, client, stream, 2)
+		}
+
+		msgs, err := client.GetCategoryMessages(context.TODO(), category)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(msgs) != 4 {
+			t.Fatalf("expected 4 messages, got %v", len(msgs))
+		}
+
+		for _, msg := range msgs {
+			if !streams[msg.Stream] {
+				t.Fatalf("unexpected message stream: %+v", msg.Stream)
+			}
+		}
+	})
+
+	t.Run("read category type", func(t *testing.T) {
+		t.Parallel()
+
+		entity := NewTestCategory("typed")
+		command := gomdb.NewStreamIdentifier(entity, []string{"command"}, GenUUID())
+		PopulateStream(t, client, command, 3)
+		PopulateStream(t, client, gomdb.NewStreamIdentifier(entity, nil, GenUUID()), 3)
+
+		msgs, err := client.GetCategoryMessages(context.TODO(), command.Category)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(msgs) != 3 {
+			t.Fatalf("expected 3 messages, got %v", len(msgs))
+		}
+
+		for _, msg := range msgs {
+			if msg.Stream != command {
+				t.Fatalf("expected message from %s, actual %s", command, msg.Stream)
+			} else if msg.Stream.EntityCategory() != entity {
+				t.Fatalf("expected entity category %s, actual %s", entity, msg.Stream.EntityCategory())
+			}
+		}
+	})
+
 	t.Run("read with correlation", func(t *testing.T) {
 		t.Parallel()
 
@@ -2,10 +2,16 @@ package tests
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/alexrudd/gomdb"
+	"github.com/lib/pq"
 )
 
 // TestSubscribeToStream tests the SubscribeToStream API.
@@ -24,7 +30,7 @@ func TestSubscribeToStream(t *testing.T) {
 		goneLive := sync.WaitGroup{}
 		goneLive.Add(1)
 
-		err := client.SubscribeToStream(
+		_, err := client.SubscribeToStream(
 			ctx,
 			stream,
 			func(m *gomdb.Message) {
@@ -59,7 +65,7 @@ func TestSubscribeToStream(t *testing.T) {
 		received := sync.WaitGroup{}
 		received.Add(3)
 
-		err := client.SubscribeToStream(
+		_, err := client.SubscribeToStream(
 			ctx,
 			stream,
 			func(m *gomdb.Message) {
@@ -90,7 +96,7 @@ func TestSubscribeToStream(t *testing.T) {
 		received.Add(10)
 		version := int64(0)
 
-		err := client.SubscribeToStream(
+		_, err := client.SubscribeToStream(
 			ctx,
 			stream,
 			func(m *gomdb.Message) {
@@ -128,7 +134,7 @@ func TestSubscribeToCategory(t *testing.T) {
 		goneLive := sync.WaitGroup{}
 		goneLive.Add(1)
 
-		err := client.SubscribeToCategory(
+		_, err := client.SubscribeToCategory(
 			ctx,
 			NewTestCategory("empty"),
 			func(m *gomdb.Message) {
@@ -166,7 +172,7 @@ func TestSubscribeToCategory(t *testing.T) {
 
 		category := NewTestCategory("empty")
 
-		err := client.SubscribeToCategory(
+		_, err := client.SubscribeToCategory(
 			ctx,
 			category,
 			func(m *gomdb.Message) {
@@ -209,7 +215,7 @@ func TestSubscribeToCategory(t *testing.T) {
 		category := NewTestCategory("empty")
 		PopulateCategory(t, client, category, 3, 10)
 
-		err := client.SubscribeToCategory(
+		_, err := client.SubscribeToCategory(
 			ctx,
 			category,
 			func(m *gomdb.Message) {
@@ -240,3 +246,764 @@ func TestSubscribeToCategory(t *testing.T) {
 		received.Wait()
 	})
 }
+
+// TestSubscription tests the Subscription handle.
+func TestSubscription(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+
+	t.Run("stop and wait", func(t *testing.T) {
+		t.Parallel()
+
+		stream := NewTestStream(NewTestCategory("stop"))
+		PopulateStream(t, client, stream, 3)
+
+		received := sync.WaitGroup{}
+		received.Add(3)
+		dropped := false
+
+		sub, err := client.SubscribeToStream(
+			context.TODO(),
+			stream,
+			func(m *gomdb.Message) {
+				received.Done()
+			},
+			func(live bool) {},
+			func(err error) {
+				if err != nil {
+					t.Errorf("received subscription error: %s", err)
+				}
+				dropped = true
+			},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		received.Wait()
+		sub.Stop()
+
+		if err := sub.Wait(); err != nil {
+			t.Fatalf("expected nil error on stop, actual: %s", err)
+		} else if !dropped {
+			t.Fatal("expected dropped handler to be called before Wait returns")
+		}
+
+		select {
+		case <-sub.Done():
+		default:
+			t.Fatal("expected done channel to be closed")
+		}
+	})
+
+	t.Run("report position", func(t *testing.T) {
+		t.Parallel()
+
+		category := PopulateCategory(t, client, NewTestCategory("position"), 2, 3)
+
+		var (
+			mu   sync.Mutex
+			last int64
+		)
+
+		goneLive := make(chan struct{})
+
+		sub, err := client.SubscribeToCategory(
+			context.TODO(),
+			category,
+			func(m *gomdb.Message) {
+				mu.Lock()
+				last = m.GlobalPosition
+				mu.Unlock()
+			},
+			func(live bool) {
+				if live {
+					close(goneLive)
+				}
+			},
+			func(err error) {},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sub.Stop()
+
+		<-goneLive
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if sub.Position() != last+1 {
+			t.Fatalf("expected position %v, actual %v", last+1, sub.Position())
+		}
+	})
+
+	t.Run("cancel parent context", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.TODO())
+
+		sub, err := client.SubscribeToCategory(
+			ctx,
+			NewTestCategory("cancel"),
+			func(m *gomdb.Message) {},
+			func(live bool) {},
+			func(err error) {},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cancel()
+
+		if err := sub.Wait(); err != nil {
+			t.Fatalf("expected nil error on cancel, actual: %s", err)
+		}
+	})
+}
+
+// TestSubscriptionFailurePolicy tests error returning handlers and failure
+// policies.
+func TestSubscriptionFailurePolicy(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+
+	t.Run("stop on failure", func(t *testing.T) {
+		t.Parallel()
+
+		stream := NewTestStream(NewTestCategory("stop"))
+		PopulateStream(t, client, stream, 5)
+		errHandler := errors.New("handler failed")
+
+		sub, err := client.SubscribeToStream(
+			context.TODO(),
+			stream,
+			nil,
+			func(live bool) {},
+			func(err error) {},
+			gomdb.WithStreamMessageHandlerE(func(m *gomdb.Message) error {
+				if m.Version == 2 {
+					return errHandler
+				}
+				return nil
+			}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := sub.Wait(); !errors.Is(err, errHandler) {
+			t.Fatalf("expected handler error, actual: %v", err)
+		} else if sub.Position() != 2 {
+			t.Fatalf("expected position 2, actual %v", sub.Position())
+		}
+	})
+
+	t.Run("dead-letter failed messages", func(t *testing.T) {
+		t.Parallel()
+
+		category := PopulateCategory(t, client, NewTestCategory("deadletter"), 2, 3)
+		deadLetter := NewTestStream(NewTestCategory("deadletter"))
+
+		var (
+			mu     sync.Mutex
+			failed = map[string]bool{}
+		)
+
+		goneLive := make(chan struct{})
+
+		sub, err := client.SubscribeToCategory(
+			context.TODO(),
+			category,
+			nil,
+			func(live bool) {
+				if live {
+					close(goneLive)
+				}
+			},
+			func(err error) {},
+			gomdb.WithCategoryMessageHandlerE(func(m *gomdb.Message) error {
+				if m.Version != 1 {
+					return nil
+				}
+
+				mu.Lock()
+				failed[m.ID] = true
+				mu.Unlock()
+
+				return errors.New("handler failed")
+			}),
+			gomdb.WithCategoryFailurePolicy(gomdb.RetryWithBackoff(
+				2, time.Millisecond, 10*time.Millisecond, 2,
+				gomdb.DeadLetterOnFailure(client, deadLetter),
+			)),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sub.Stop()
+
+		<-goneLive
+
+		msgs, err := client.GetStreamMessages(context.TODO(), deadLetter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(msgs) != 2 {
+			t.Fatalf("expected 2 dead-letter messages, got %v", len(msgs))
+		}
+
+		for _, msg := range msgs {
+			metadata := gomdb.DeadLetterMetadata{}
+			if err := msg.UnmarshalMetadata(&metadata); err != nil {
+				t.Fatal(err)
+			}
+
+			if !failed[metadata.OriginalMessageID] {
+				t.Fatalf("unexpected dead-letter message: %+v", metadata)
+			} else if metadata.OriginalPosition != 1 || metadata.Error != "handler failed" {
+				t.Fatalf("unexpected dead-letter metadata: %+v", metadata)
+			}
+
+			var data string
+			if err := msg.UnmarshalData(&data); err != nil {
+				t.Fatal(err)
+			} else if data != "data" {
+				t.Fatalf("expected original data, actual %s", data)
+			}
+		}
+	})
+}
+
+// TestSubscribeToCategoryWorkers tests handling category messages on multiple
+// workers.
+func TestSubscribeToCategoryWorkers(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+	category := PopulateCategory(t, client, NewTestCategory("workers"), 4, 5)
+
+	var (
+		mu       sync.Mutex
+		versions = map[gomdb.StreamIdentifier]int64{}
+		handled  = sync.WaitGroup{}
+	)
+
+	handled.Add(20)
+
+	sub, err := client.SubscribeToCategory(
+		context.TODO(),
+		category,
+		func(m *gomdb.Message) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if expected := versions[m.Stream]; m.Version != expected {
+				t.Errorf("stream %s: expected version %v, actual %v", m.Stream, expected, m.Version)
+			}
+			versions[m.Stream] = m.Version + 1
+
+			handled.Done()
+		},
+		func(live bool) {},
+		func(err error) {
+			if err != nil {
+				t.Errorf("received subscription error: %s", err)
+			}
+		},
+		gomdb.WithCategoryWorkers(3),
+		gomdb.WithCategoryBatchSize(7),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handled.Wait()
+
+	msgs, err := client.GetCategoryMessages(context.TODO(), category)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the position follows the last message once every message has completed.
+	expected := msgs[len(msgs)-1].GlobalPosition + 1
+	deadline := time.Now().Add(time.Second)
+
+	for sub.Position() != expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected position %v, actual %v", expected, sub.Position())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	sub.Stop()
+
+	if err := sub.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSubscriptionBatchHandler tests handling messages a batch at a time.
+func TestSubscriptionBatchHandler(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+	category := PopulateCategory(t, client, NewTestCategory("batch"), 3, 4)
+
+	var sizes []int
+	goneLive := make(chan struct{})
+
+	sub, err := client.SubscribeToCategory(
+		context.TODO(),
+		category,
+		nil,
+		func(live bool) {
+			if live {
+				close(goneLive)
+			}
+		},
+		func(err error) {
+			if err != nil {
+				t.Errorf("received subscription error: %s", err)
+			}
+		},
+		gomdb.WithCategoryBatchSize(5),
+		gomdb.WithCategoryBatchHandler(func(batch []*gomdb.Message) error {
+			sizes = append(sizes, len(batch))
+			return nil
+		}, 0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-goneLive
+	sub.Stop()
+
+	if err := sub.Wait(); err != nil {
+		t.Fatal(err)
+	} else if len(sizes) != 3 || sizes[0] != 5 || sizes[1] != 5 || sizes[2] != 2 {
+		t.Fatalf("expected batches of 5, 5 and 2, actual %v", sizes)
+	}
+}
+
+// TestSubscriptionLivenessRules tests going live within a lag threshold.
+func TestSubscriptionLivenessRules(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+
+	testcases := []struct {
+		name      string
+		subscribe func(handle gomdb.MessageHandler, handleLiveness gomdb.LivenessHandler, handleDropped gomdb.SubDroppedHandler) (*gomdb.Subscription, error)
+	}{
+		{
+			name: "stream",
+			subscribe: func(handle gomdb.MessageHandler, handleLiveness gomdb.LivenessHandler, handleDropped gomdb.SubDroppedHandler) (*gomdb.Subscription, error) {
+				stream := NewTestStream(NewTestCategory("liveness"))
+				PopulateStream(t, client, stream, 10)
+
+				return client.SubscribeToStream(context.TODO(), stream, handle, handleLiveness, handleDropped,
+					gomdb.WithStreamBatchSize(2),
+					gomdb.WithStreamLivenessRules(gomdb.LivenessRules{MaxLagMessages: 6}),
+				)
+			},
+		},
+		{
+			name: "category",
+			subscribe: func(handle gomdb.MessageHandler, handleLiveness gomdb.LivenessHandler, handleDropped gomdb.SubDroppedHandler) (*gomdb.Subscription, error) {
+				category := PopulateCategory(t, client, NewTestCategory("liveness"), 2, 5)
+
+				return client.SubscribeToCategory(context.TODO(), category, handle, handleLiveness, handleDropped,
+					gomdb.WithCategoryBatchSize(2),
+					gomdb.WithCategoryLivenessRules(gomdb.LivenessRules{MaxLagMessages: 6}),
+				)
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				handled     int
+				changes     []bool
+				handledLive int
+			)
+
+			caughtUp := make(chan struct{})
+
+			sub, err := tc.subscribe(
+				func(m *gomdb.Message) {
+					if handled++; handled == 10 {
+						close(caughtUp)
+					}
+				},
+				func(live bool) {
+					if live {
+						handledLive = handled
+					}
+					changes = append(changes, live)
+				},
+				func(err error) {
+					if err != nil {
+						t.Errorf("received subscription error: %s", err)
+					}
+				},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			<-caughtUp
+			sub.Stop()
+
+			if err := sub.Wait(); err != nil {
+				t.Fatal(err)
+			}
+
+			// full batches keep the subscription live once it is within 6
+			// messages.
+			if len(changes) != 1 || !changes[0] {
+				t.Fatalf("expected to go live once, actual %v", changes)
+			} else if handledLive != 4 {
+				t.Fatalf("expected to go live after 4 messages, actual %v", handledLive)
+			}
+		})
+	}
+}
+
+// TestSubscriptionRestartPolicy tests restarting subscriptions that fail with
+// transient errors.
+func TestSubscriptionRestartPolicy(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+	stream := NewTestStream(NewTestCategory("restart"))
+	PopulateStream(t, client, stream, 5)
+
+	var (
+		restarts []gomdb.SubscriptionRestart
+		handled  []int64
+		failed   bool
+	)
+
+	goneLive := make(chan struct{})
+
+	sub, err := client.SubscribeToStream(
+		context.TODO(),
+		stream,
+		nil,
+		func(live bool) {
+			if live {
+				close(goneLive)
+			}
+		},
+		func(err error) {},
+		gomdb.WithStreamMessageHandlerE(func(m *gomdb.Message) error {
+			// fail the third message once with a transient error.
+			if m.Version == 2 && !failed {
+				failed = true
+				return syscall.ECONNRESET
+			}
+
+			handled = append(handled, m.Version)
+			return nil
+		}),
+		gomdb.WithStreamRestartPolicy(
+			gomdb.RestartWithBackoff(3, time.Millisecond, 10*time.Millisecond, 2, nil),
+			func(restart gomdb.SubscriptionRestart) {
+				restarts = append(restarts, restart)
+			},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-goneLive
+	sub.Stop()
+
+	if err := sub.Wait(); err != nil {
+		t.Fatal(err)
+	} else if len(restarts) != 1 || restarts[0].Position != 2 {
+		t.Fatalf("expected 1 restart from version 2, actual %+v", restarts)
+	} else if len(handled) != 5 {
+		t.Fatalf("expected 5 handled messages, actual %v", handled)
+	}
+}
+
+// TestIsTransientError tests that Postgres errors returned by each driver are
+// classified by their SQLSTATE code.
+func TestIsTransientError(t *testing.T) {
+	t.Parallel()
+
+	// raiseSQL raises an error with the SQLSTATE code of its argument.
+	const raiseSQL = "DO $$ BEGIN RAISE EXCEPTION 'raised' USING ERRCODE = '%s'; END $$"
+
+	testcases := []struct {
+		name     string
+		state    string
+		expected bool
+	}{
+		{name: "serialization failure", state: "40001", expected: true},
+		{name: "unique violation", state: "23505", expected: false},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+
+		t.Run("lib/pq "+tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := OpenDB(t).ExecContext(context.TODO(), fmt.Sprintf(raiseSQL, tc.state))
+
+			var pqErr *pq.Error
+			if !errors.As(err, &pqErr) {
+				t.Fatalf("expected *pq.Error, actual %v", err)
+			} else if actual := gomdb.IsTransientError(err); actual != tc.expected {
+				t.Fatalf("expected %v, actual %v", tc.expected, actual)
+			}
+		})
+
+		t.Run("pgx "+tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := OpenPool(t).Exec(context.TODO(), fmt.Sprintf(raiseSQL, tc.state))
+			if err == nil {
+				t.Fatal("expected error")
+			} else if actual := gomdb.IsTransientError(err); actual != tc.expected {
+				t.Fatalf("expected %v, actual %v", tc.expected, actual)
+			}
+		})
+	}
+
+	t.Run("lib/pq terminated backend", func(t *testing.T) {
+		t.Parallel()
+
+		db := OpenDB(t)
+
+		conn, err := db.Conn(context.TODO())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		var pid int
+		if err := conn.QueryRowContext(context.TODO(), "SELECT pg_backend_pid()").Scan(&pid); err != nil {
+			t.Fatal(err)
+		} else if _, err := db.ExecContext(context.TODO(), "SELECT pg_terminate_backend($1)", pid); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = conn.ExecContext(context.TODO(), "SELECT 1")
+		if err == nil {
+			t.Fatal("expected error")
+		} else if !gomdb.IsTransientError(err) {
+			t.Fatalf("expected transient error, actual %v", err)
+		}
+	})
+}
+
+var errInjected = errors.New("injected failure")
+
+// failingBackend is a gomdb.Backend whose queries fail once a number of
+// queries have succeeded.
+type failingBackend struct {
+	gomdb.Backend
+	mu        sync.Mutex
+	failAfter int
+}
+
+func (b *failingBackend) Query(ctx context.Context, query string, args ...interface{}) (gomdb.Rows, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failAfter--; b.failAfter < 0 {
+		return nil, errInjected
+	}
+
+	return b.Backend.Query(ctx, query, args...)
+}
+
+// TestSubscriptionErrors tests that the errors stopping subscriptions are
+// reported to the dropped handler and by Wait.
+func TestSubscriptionErrors(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+
+	t.Run("failed poll", func(t *testing.T) {
+		t.Parallel()
+
+		stream := NewTestStream(NewTestCategory("failedpoll"))
+		PopulateStream(t, client, stream, 5)
+
+		// the third poll fails.
+		failing := gomdb.NewClientWithBackend(&failingBackend{
+			Backend:   NewBackend(t),
+			failAfter: 2,
+		})
+
+		var dropped error
+
+		sub, err := failing.SubscribeToStream(
+			context.TODO(),
+			stream,
+			func(m *gomdb.Message) {},
+			func(live bool) {},
+			func(err error) {
+				dropped = err
+			},
+			gomdb.WithStreamBatchSize(2),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = sub.Wait()
+		if !errors.Is(err, errInjected) {
+			t.Fatalf("expected injected error, actual: %v", err)
+		} else if dropped != err {
+			t.Fatalf("expected dropped handler to receive %v, actual: %v", err, dropped)
+		}
+
+		subErr := &gomdb.SubscriptionError{}
+		if !errors.As(err, &subErr) {
+			t.Fatalf("expected a SubscriptionError, actual %T", err)
+		} else if subErr.Stream != stream || subErr.Position != 4 || subErr.Attempt != 3 {
+			t.Fatalf("expected failure on poll 3 of %s at version 4, actual %+v", stream, subErr)
+		}
+	})
+
+	t.Run("closed database", func(t *testing.T) {
+		t.Parallel()
+
+		db := OpenDB(t)
+		closing := gomdb.NewClient(db)
+		category := PopulateCategory(t, client, NewTestCategory("closed"), 1, 3)
+		dropped := make(chan error, 1)
+
+		sub, err := closing.SubscribeToCategory(
+			context.TODO(),
+			category,
+			func(m *gomdb.Message) {},
+			func(live bool) {
+				if live {
+					db.Close()
+				}
+			},
+			func(err error) {
+				dropped <- err
+			},
+			gomdb.WithCategoryPollingStrategy(gomdb.ConstantPolling(time.Millisecond)()),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := <-dropped; err == nil {
+			t.Fatal("expected dropped handler to receive the database error")
+		}
+
+		subErr := &gomdb.SubscriptionError{}
+		if err := sub.Wait(); !errors.As(err, &subErr) {
+			t.Fatalf("expected a SubscriptionError, actual %v", err)
+		} else if !subErr.Stream.IsCategory() || subErr.Stream.Category != category {
+			t.Fatalf("expected error for category %s, actual %s", category, subErr.Stream)
+		}
+	})
+
+	t.Run("failed handler", func(t *testing.T) {
+		t.Parallel()
+
+		category := PopulateCategory(t, client, NewTestCategory("failedhandler"), 2, 2)
+		errHandler := errors.New("handler failed")
+
+		sub, err := client.SubscribeToCategory(
+			context.TODO(),
+			category,
+			nil,
+			func(live bool) {},
+			func(err error) {},
+			gomdb.WithCategoryMessageHandlerE(func(m *gomdb.Message) error {
+				return errHandler
+			}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		subErr := &gomdb.SubscriptionError{}
+		if err := sub.Wait(); !errors.Is(err, errHandler) || !errors.As(err, &subErr) {
+			t.Fatalf("expected handler SubscriptionError, actual %v", err)
+		} else if subErr.Attempt != 1 {
+			t.Fatalf("expected failure on the first poll, actual %v", subErr.Attempt)
+		}
+	})
+}
+
+// TestSubscriptionPanics tests that handler panics are recovered and passed to
+// the failure policy.
+func TestSubscriptionPanics(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+	stream := NewTestStream(NewTestCategory("panic"))
+	deadLetter := NewTestStream(NewTestCategory("panic"))
+	PopulateStream(t, client, stream, 3)
+
+	handled := 0
+	goneLive := make(chan struct{})
+
+	sub, err := client.SubscribeToStream(
+		context.TODO(),
+		stream,
+		func(m *gomdb.Message) {
+			if m.Version == 1 {
+				panic("poison message")
+			}
+			handled++
+		},
+		func(live bool) {
+			if live {
+				close(goneLive)
+			}
+		},
+		func(err error) {
+			if err != nil {
+				t.Errorf("received subscription error: %s", err)
+			}
+		},
+		gomdb.WithStreamFailurePolicy(gomdb.DeadLetterOnFailure(client, deadLetter)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-goneLive
+	sub.Stop()
+
+	if err := sub.Wait(); err != nil {
+		t.Fatal(err)
+	} else if handled != 2 {
+		t.Fatalf("expected 2 handled messages, actual %v", handled)
+	}
+
+	msg, err := client.GetLastStreamMessage(context.TODO(), deadLetter)
+	if err != nil {
+		t.Fatal(err)
+	} else if msg == nil {
+		t.Fatal("expected the panicking message to be dead-lettered")
+	}
+
+	metadata := gomdb.DeadLetterMetadata{}
+	if err := msg.UnmarshalMetadata(&metadata); err != nil {
+		t.Fatal(err)
+	} else if metadata.OriginalPosition != 1 || !strings.Contains(metadata.Error, "poison message") {
+		t.Fatalf("expected panic of message 1 to be recorded, actual %+v", metadata)
+	}
+}
@@ -0,0 +1,329 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/alexrudd/gomdb"
+)
+
+// TestInTx tests the InTx API.
+func TestInTx(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+
+	t.Run("commit writes to multiple streams", func(t *testing.T) {
+		t.Parallel()
+
+		entity := NewTestStream(NewTestCategory("entity"))
+		reply := NewTestStream(NewTestCategory("reply"))
+
+		err := client.InTx(context.TODO(), func(tx *gomdb.TxClient) error {
+			version, err := tx.GetStreamVersion(context.TODO(), entity)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.WriteMessage(context.TODO(), entity, gomdb.ProposedMessage{
+				ID:   GenUUID(),
+				Type: "TestMessage",
+				Data: "data",
+			}, version)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.WriteMessage(context.TODO(), reply, gomdb.ProposedMessage{
+				ID:   GenUUID(),
+				Type: "TestReply",
+				Data: "data",
+			}, gomdb.NoStreamVersion)
+
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, stream := range []gomdb.StreamIdentifier{entity, reply} {
+			version, err := client.GetStreamVersion(context.TODO(), stream)
+			if err != nil {
+				t.Fatal(err)
+			} else if version != 0 {
+				t.Fatalf("expected stream version 0, actual %v", version)
+			}
+		}
+	})
+
+	t.Run("read own writes", func(t *testing.T) {
+		t.Parallel()
+
+		stream := NewTestStream(NewTestCategory("entity"))
+
+		err := client.InTx(context.TODO(), func(tx *gomdb.TxClient) error {
+			_, err := tx.WriteMessage(context.TODO(), stream, gomdb.ProposedMessage{
+				ID:   GenUUID(),
+				Type: "TestMessage",
+				Data: "data",
+			}, gomdb.NoStreamVersion)
+			if err != nil {
+				return err
+			}
+
+			msg, err := tx.GetLastStreamMessage(context.TODO(), stream)
+			if err != nil {
+				return err
+			} else if msg == nil {
+				return errors.New("expected message but got nil")
+			}
+
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("rollback on error", func(t *testing.T) {
+		t.Parallel()
+
+		entity := NewTestStream(NewTestCategory("entity"))
+		reply := NewTestStream(NewTestCategory("reply"))
+		PopulateStream(t, client, reply, 1)
+
+		err := client.InTx(context.TODO(), func(tx *gomdb.TxClient) error {
+			_, err := tx.WriteMessage(context.TODO(), entity, gomdb.ProposedMessage{
+				ID:   GenUUID(),
+				Type: "TestMessage",
+				Data: "data",
+			}, gomdb.NoStreamVersion)
+			if err != nil {
+				return err
+			}
+
+			// reply stream already exists so this write must fail.
+			_, err = tx.WriteMessage(context.TODO(), reply, gomdb.ProposedMessage{
+				ID:   GenUUID(),
+				Type: "TestReply",
+				Data: "data",
+			}, gomdb.NoStreamVersion)
+
+			return err
+		})
+		if !errors.Is(err, gomdb.ErrUnexpectedStreamVersion) {
+			t.Fatalf("expected OCC failure, actual: %v", err)
+		}
+
+		version, err := client.GetStreamVersion(context.TODO(), entity)
+		if err != nil {
+			t.Fatal(err)
+		} else if version != gomdb.NoStreamVersion {
+			t.Fatalf("expected stream version %v, actual %v", gomdb.NoStreamVersion, version)
+		}
+	})
+
+	t.Run("rollback on panic", func(t *testing.T) {
+		t.Parallel()
+
+		stream := NewTestStream(NewTestCategory("entity"))
+
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Fatal("expected panic to be propagated")
+				}
+			}()
+
+			_ = client.InTx(context.TODO(), func(tx *gomdb.TxClient) error {
+				_, err := tx.WriteMessage(context.TODO(), stream, gomdb.ProposedMessage{
+					ID:   GenUUID(),
+					Type: "TestMessage",
+					Data: "data",
+				}, gomdb.NoStreamVersion)
+				if err != nil {
+					return err
+				}
+
+				panic("handler panicked")
+			})
+		}()
+
+		version, err := client.GetStreamVersion(context.TODO(), stream)
+		if err != nil {
+			t.Fatal(err)
+		} else if version != gomdb.NoStreamVersion {
+			t.Fatalf("expected stream version %v, actual %v", gomdb.NoStreamVersion, version)
+		}
+	})
+}
+
+// TestNewClient tests creating clients over the different DB implementations.
+func TestNewClient(t *testing.T) {
+	t.Parallel()
+
+	db := OpenDB(t)
+
+	t.Run("client over transaction", func(t *testing.T) {
+		t.Parallel()
+
+		stream := NewTestStream(NewTestCategory("tx"))
+
+		tx, err := db.BeginTx(context.TODO(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+
+		client := gomdb.NewClient(tx)
+		PopulateStream(t, client, stream, 2)
+
+		// InTx joins the enclosing transaction.
+		err = client.InTx(context.TODO(), func(tx *gomdb.TxClient) error {
+			_, err := tx.WriteMessage(context.TODO(), stream, gomdb.ProposedMessage{
+				ID:   GenUUID(),
+				Type: "TestMessage",
+				Data: "data",
+			}, 1)
+
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = tx.Rollback(); err != nil {
+			t.Fatal(err)
+		}
+
+		version, err := gomdb.NewClient(db).GetStreamVersion(context.TODO(), stream)
+		if err != nil {
+			t.Fatal(err)
+		} else if version != gomdb.NoStreamVersion {
+			t.Fatalf("expected stream version %v, actual %v", gomdb.NoStreamVersion, version)
+		}
+	})
+
+	t.Run("client over connection", func(t *testing.T) {
+		t.Parallel()
+
+		conn, err := db.Conn(context.TODO())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		_, err = conn.ExecContext(context.TODO(), "SET search_path TO message_store,public;")
+		if err != nil {
+			t.Fatalf("setting search path: %s", err)
+		}
+
+		client := gomdb.NewClient(conn)
+		stream := NewTestStream(NewTestCategory("conn"))
+
+		version, err := client.WriteMessages(context.TODO(), stream, []gomdb.ProposedMessage{
+			{ID: GenUUID(), Type: "TestMessage", Data: "data"},
+			{ID: GenUUID(), Type: "TestMessage", Data: "data"},
+		}, gomdb.NoStreamVersion)
+		if err != nil {
+			t.Fatal(err)
+		} else if version != 1 {
+			t.Fatalf("expected ver 1, got version %v", version)
+		}
+	})
+}
+
+// wrappedDB wraps a *sql.DB without exposing BeginTx, like an instrumented
+// handle.
+type wrappedDB struct {
+	db *sql.DB
+}
+
+func (w wrappedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return w.db.PrepareContext(ctx, query)
+}
+
+func (w wrappedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return w.db.QueryContext(ctx, query, args...)
+}
+
+func (w wrappedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return w.db.ExecContext(ctx, query, args...)
+}
+
+// beginningDB is a wrapper that also implements BeginTx.
+type beginningDB struct {
+	wrappedDB
+}
+
+func (w beginningDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return w.db.BeginTx(ctx, opts)
+}
+
+// TestWrappedDB tests that transactions are only run over wrappers that can
+// begin them.
+func TestWrappedDB(t *testing.T) {
+	t.Parallel()
+
+	db := OpenDB(t)
+	msgs := []gomdb.ProposedMessage{
+		{ID: GenUUID(), Type: "TestMessage", Data: "data"},
+		{ID: GenUUID(), Type: "TestMessage", Data: "data"},
+	}
+
+	t.Run("without BeginTx", func(t *testing.T) {
+		t.Parallel()
+
+		client := gomdb.NewClient(wrappedDB{db: db})
+		stream := NewTestStream(NewTestCategory("wrapped"))
+
+		_, err := client.WriteMessages(context.TODO(), stream, msgs, gomdb.NoStreamVersion)
+		if !errors.Is(err, gomdb.ErrTxNotSupported) {
+			t.Fatalf("expected %v, actual %v", gomdb.ErrTxNotSupported, err)
+		}
+
+		version, err := client.GetStreamVersion(context.TODO(), stream)
+		if err != nil {
+			t.Fatal(err)
+		} else if version != gomdb.NoStreamVersion {
+			t.Fatalf("expected nothing to be written, actual version %v", version)
+		}
+	})
+
+	t.Run("with BeginTx", func(t *testing.T) {
+		t.Parallel()
+
+		client := gomdb.NewClient(beginningDB{wrappedDB{db: db}})
+		stream := NewTestStream(NewTestCategory("wrapped"))
+
+		version, err := client.WriteMessages(context.TODO(), stream, msgs, gomdb.NoStreamVersion)
+		if err != nil {
+			t.Fatal(err)
+		} else if version != 1 {
+			t.Fatalf("expected version 1, actual %v", version)
+		}
+	})
+}
+
+// TestClose tests that a Client can continue to be used after its prepared
+// statements have been released.
+func TestClose(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+	stream := NewTestStream(NewTestCategory("close"))
+	PopulateStream(t, client, stream, 1)
+
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := client.GetStreamVersion(context.TODO(), stream)
+	if err != nil {
+		t.Fatal(err)
+	} else if version != 0 {
+		t.Fatalf("expected stream version 0, actual %v", version)
+	}
+}
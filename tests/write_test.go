@@ -81,3 +81,113 @@ func TestWriteMessage(t *testing.T) {
 		}
 	})
 }
+
+// TestWriteMessages tests the WriteMessages API.
+func TestWriteMessages(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(t)
+
+	newMessages := func(n int) []gomdb.ProposedMessage {
+		msgs := make([]gomdb.ProposedMessage, n)
+		for i := range msgs {
+			msgs[i] = gomdb.ProposedMessage{
+				ID:   GenUUID(),
+				Type: "TestMessage",
+				Data: "data",
+			}
+		}
+
+		return msgs
+	}
+
+	t.Run("stream does not exist", func(t *testing.T) {
+		t.Parallel()
+
+		stream := NewTestStream(NewTestCategory("new_stream"))
+
+		version, err := client.WriteMessages(context.TODO(), stream, newMessages(3), gomdb.NoStreamVersion)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if version != 2 {
+			t.Fatalf("expected ver 2, got version %v", version)
+		}
+	})
+
+	t.Run("append to existing stream", func(t *testing.T) {
+		t.Parallel()
+
+		stream := NewTestStream(NewTestCategory("existing_stream"))
+		PopulateStream(t, client, stream, 2)
+
+		version, err := client.WriteMessages(context.TODO(), stream, newMessages(3), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if version != 4 {
+			t.Fatalf("expected ver 4, got version %v", version)
+		}
+	})
+
+	t.Run("skip OCC check", func(t *testing.T) {
+		t.Parallel()
+
+		stream := NewTestStream(NewTestCategory("any_stream"))
+		PopulateStream(t, client, stream, 1)
+
+		version, err := client.WriteMessages(context.TODO(), stream, newMessages(2), gomdb.AnyVersion)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if version != 2 {
+			t.Fatalf("expected ver 2, got version %v", version)
+		}
+	})
+
+	t.Run("fail OCC check rolls back batch", func(t *testing.T) {
+		t.Parallel()
+
+		stream := NewTestStream(NewTestCategory("any_stream"))
+		PopulateStream(t, client, stream, 1)
+
+		_, err := client.WriteMessages(context.TODO(), stream, newMessages(3), gomdb.NoStreamVersion)
+		if !errors.Is(err, gomdb.ErrUnexpectedStreamVersion) {
+			t.Fatalf("expected OCC failure, actual: %v", err)
+		}
+
+		version, err := client.GetStreamVersion(context.TODO(), stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if version != 0 {
+			t.Fatalf("expected stream version 0, actual %v", version)
+		}
+	})
+
+	t.Run("duplicate message rolls back batch", func(t *testing.T) {
+		t.Parallel()
+
+		stream := NewTestStream(NewTestCategory("duplicate"))
+		msgs := newMessages(3)
+		msgs[2].ID = msgs[0].ID
+
+		_, err := client.WriteMessages(context.TODO(), stream, msgs, gomdb.NoStreamVersion)
+		if err == nil {
+			t.Fatal("expected write failure")
+		}
+
+		version, err := client.GetStreamVersion(context.TODO(), stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if version != gomdb.NoStreamVersion {
+			t.Fatalf("expected stream version %v, actual %v", gomdb.NoStreamVersion, version)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package gomdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// TxClient exposes the Client read and write methods within a single database
+// transaction. A TxClient is only valid inside the function passed to
+// Client.InTx.
+type TxClient struct {
+	client *Client
+}
+
+// InTx runs fn within a single database transaction. The transaction is
+// committed if fn returns nil, and is rolled back if fn returns an error or
+// panics. If the Client was created over a *sql.Tx then fn joins that
+// transaction instead, and committing it is left to its owner.
+func (c *Client) InTx(ctx context.Context, fn func(tx *TxClient) error) error {
+	if fn == nil {
+		return errors.New("transaction function is required")
+	}
+
+	return c.withTx(ctx, nil, func(tc *Client) error {
+		return fn(&TxClient{client: tc})
+	})
+}
+
+// withTx calls fn with a Client bound to a new transaction. If the Client's
+// Backend is already bound to a transaction then fn may join it instead.
+func (c *Client) withTx(ctx context.Context, opts *sql.TxOptions, fn func(*Client) error) error {
+	tx, err := c.backend.BeginTx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+
+	// rollback is a no-op once the transaction has been committed, and also
+	// covers fn panicking.
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	tc := &Client{
+		backend:             tx,
+		defaultPollingStrat: c.defaultPollingStrat,
+	}
+
+	if err = fn(tc); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// WriteMessage attempts to write the proposed message to the specified stream
+// within the transaction.
+func (tx *TxClient) WriteMessage(ctx context.Context, stream StreamIdentifier, message ProposedMessage, expectedVersion int64) (int64, error) {
+	return tx.client.WriteMessage(ctx, stream, message, expectedVersion)
+}
+
+// WriteMessages attempts to write all of the proposed messages to the
+// specified stream within the transaction.
+func (tx *TxClient) WriteMessages(ctx context.Context, stream StreamIdentifier, messages []ProposedMessage, expectedVersion int64) (int64, error) {
+	return tx.client.WriteMessages(ctx, stream, messages, expectedVersion)
+}
+
+// GetStreamMessages reads messages from an individual stream within the
+// transaction.
+func (tx *TxClient) GetStreamMessages(ctx context.Context, stream StreamIdentifier, opts ...GetStreamOption) ([]*Message, error) {
+	return tx.client.GetStreamMessages(ctx, stream, opts...)
+}
+
+// GetCategoryMessages reads messages from a category within the transaction.
+func (tx *TxClient) GetCategoryMessages(ctx context.Context, category string, opts ...GetCategoryOption) ([]*Message, error) {
+	return tx.client.GetCategoryMessages(ctx, category, opts...)
+}
+
+// GetLastStreamMessage returns the last message for the specified stream
+// within the transaction, or nil if the stream is empty.
+func (tx *TxClient) GetLastStreamMessage(ctx context.Context, stream StreamIdentifier) (*Message, error) {
+	return tx.client.GetLastStreamMessage(ctx, stream)
+}
+
+// GetStreamVersion returns the version of the specified stream within the
+// transaction.
+func (tx *TxClient) GetStreamVersion(ctx context.Context, stream StreamIdentifier) (int64, error) {
+	return tx.client.GetStreamVersion(ctx, stream)
+}
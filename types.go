@@ -25,13 +25,27 @@ var (
 	// ErrInvalidCategory is returned when the stream identifier category
 	// contains the reserved stream name seperator character.
 	ErrInvalidCategory = fmt.Errorf("category cannot contain separator (%s)", StreamNameSeparator)
+	// ErrInvalidCategoryType is returned when one of the stream identifier
+	// category types is blank or contains the category type separator.
+	ErrInvalidCategoryType = fmt.Errorf("category types cannot be blank or contain separator (%s)", CategoryTypeSeparator)
 	// ErrMissingStreamID is returned when the stream identifier ID is missing.
 	ErrMissingStreamID = errors.New("ID cannot be blank")
+	// ErrInvalidStreamID is returned when one of the IDs that make up a
+	// compound stream identifier ID is blank.
+	ErrInvalidStreamID = fmt.Errorf("compound IDs cannot be blank (%s)", CompoundIDSeparator)
 )
 
-// StreamNameSeparator is the character used to separate the stream category
-// from the stream ID in a stream name.
-const StreamNameSeparator = "-"
+const (
+	// StreamNameSeparator is the character used to separate the stream
+	// category from the stream ID in a stream name.
+	StreamNameSeparator = "-"
+	// CategoryTypeSeparator is the character used to separate the entity
+	// category from the category types, for example "account:command".
+	CategoryTypeSeparator = ":"
+	// CompoundIDSeparator is the character used to join multiple category
+	// types or IDs, for example "account-123+456".
+	CompoundIDSeparator = "+"
+)
 
 // Message represents a message that was stored in message-db.
 type Message struct {
@@ -63,6 +77,8 @@ func deserialiseMessage(row scanner) (*Message, error) {
 		return nil, err
 	}
 
+	msg.Stream = parseStreamName(streamName)
+
 	return msg, nil
 }
 
@@ -99,23 +115,156 @@ func (pm *ProposedMessage) validate() error {
 }
 
 // StreamIdentifier captures the two components of a message-db stream name.
+// The Category may include category types after a colon, for example
+// "account:command", and the ID may be a compound ID joined with a plus, for
+// example "123+456". Use Types, IDs and CardinalID to access the structured
+// parts. Both components are kept as strings so that stream identifiers remain
+// comparable.
 type StreamIdentifier struct {
 	Category string
 	ID       string
 }
 
+// NewStreamIdentifier returns a StreamIdentifier composed from an entity
+// category, optional category types and one or more IDs. For example
+// NewStreamIdentifier("account", []string{"command"}, "123", "456") identifies
+// the stream "account:command-123+456".
+func NewStreamIdentifier(category string, types []string, ids ...string) StreamIdentifier {
+	if len(types) > 0 {
+		category += CategoryTypeSeparator + strings.Join(types, CompoundIDSeparator)
+	}
+
+	return StreamIdentifier{
+		Category: category,
+		ID:       strings.Join(ids, CompoundIDSeparator),
+	}
+}
+
+// ParseStreamName parses a stream name into a StreamIdentifier, splitting the
+// category from the ID at the first stream name separator. A name without a
+// separator is parsed as a category, see IsCategory. An error is returned if
+// any of the category, category types or IDs are blank.
+func ParseStreamName(name string) (StreamIdentifier, error) {
+	si := parseStreamName(name)
+
+	if err := validateCategory(si.Category); err != nil {
+		return si, err
+	} else if strings.Contains(name, StreamNameSeparator) {
+		if err := si.validateID(); err != nil {
+			return si, err
+		}
+	}
+
+	return si, nil
+}
+
 // String returns the string respresentation of a StreamIdentifier.
 func (si StreamIdentifier) String() string {
+	if si.ID == "" {
+		return si.Category
+	}
+
 	return si.Category + StreamNameSeparator + si.ID
 }
 
+// EntityCategory returns the category without any category types, for example
+// "account" for the stream "account:command-123".
+func (si StreamIdentifier) EntityCategory() string {
+	return strings.SplitN(si.Category, CategoryTypeSeparator, 2)[0]
+}
+
+// Types returns the category types, for example ["command", "position"] for
+// the stream "account:command+position-123". Nil is returned if the category
+// has no types.
+func (si StreamIdentifier) Types() []string {
+	parts := strings.SplitN(si.Category, CategoryTypeSeparator, 2)
+	if len(parts) == 1 {
+		return nil
+	}
+
+	return strings.Split(parts[1], CompoundIDSeparator)
+}
+
+// IDs returns each of the IDs that make up a compound ID, for example
+// ["123", "456"] for the stream "account-123+456". Nil is returned if the
+// identifier has no ID.
+func (si StreamIdentifier) IDs() []string {
+	if si.ID == "" {
+		return nil
+	}
+
+	return strings.Split(si.ID, CompoundIDSeparator)
+}
+
+// CardinalID returns the first ID of a compound ID, or the ID itself if it is
+// not compound. This mirrors Message DB's cardinal_id function, and is the
+// value used to assign streams to consumer group members.
+func (si StreamIdentifier) CardinalID() string {
+	return strings.SplitN(si.ID, CompoundIDSeparator, 2)[0]
+}
+
+// IsCategory returns true if the identifier has no ID and so names a category
+// rather than a stream. This mirrors Message DB's is_category function.
+func (si StreamIdentifier) IsCategory() bool {
+	return si.ID == ""
+}
+
+// parseStreamName splits a stream name into its category and ID at the first
+// stream name separator. Category types (account:command) remain part of the
+// category and compound IDs (123+456) remain part of the ID, so String always
+// returns the original stream name.
+func parseStreamName(name string) StreamIdentifier {
+	parts := strings.SplitN(name, StreamNameSeparator, 2)
+	if len(parts) == 1 {
+		return StreamIdentifier{Category: name}
+	}
+
+	return StreamIdentifier{
+		Category: parts[0],
+		ID:       parts[1],
+	}
+}
+
 func (si StreamIdentifier) validate() error {
-	if si.Category == "" {
+	if err := validateCategory(si.Category); err != nil {
+		return err
+	}
+
+	return si.validateID()
+}
+
+func (si StreamIdentifier) validateID() error {
+	if si.ID == "" {
+		return ErrMissingStreamID
+	}
+
+	for _, id := range si.IDs() {
+		if id == "" {
+			return ErrInvalidStreamID
+		}
+	}
+
+	return nil
+}
+
+// validateCategory checks that a category, including any category types, is
+// valid.
+func validateCategory(category string) error {
+	if category == "" {
 		return ErrMissingCategory
-	} else if strings.Contains(si.Category, StreamNameSeparator) {
+	} else if strings.Contains(category, StreamNameSeparator) {
 		return ErrInvalidCategory
-	} else if si.ID == "" {
-		return ErrMissingStreamID
+	}
+
+	si := StreamIdentifier{Category: category}
+	if si.EntityCategory() == "" {
+		return ErrMissingCategory
+	}
+
+	for _, t := range si.Types() {
+		if t == "" || strings.Contains(t, CategoryTypeSeparator) {
+			return ErrInvalidCategoryType
+		}
 	}
 
 	return nil
@@ -3,7 +3,9 @@ package gomdb
 import (
 	"encoding/json"
 	"errors"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func Test_ProposedMessage_validate(t *testing.T) {
@@ -85,6 +87,38 @@ func Test_StreamIdentifier_validate(t *testing.T) {
 			},
 			expErr: ErrMissingStreamID,
 		},
+		{
+			name: "missing entity category",
+			sid: StreamIdentifier{
+				Category: ":command",
+				ID:       "123abc",
+			},
+			expErr: ErrMissingCategory,
+		},
+		{
+			name: "blank category type",
+			sid: StreamIdentifier{
+				Category: "category:command+",
+				ID:       "123abc",
+			},
+			expErr: ErrInvalidCategoryType,
+		},
+		{
+			name: "invalid category type",
+			sid: StreamIdentifier{
+				Category: "category:command:position",
+				ID:       "123abc",
+			},
+			expErr: ErrInvalidCategoryType,
+		},
+		{
+			name: "blank compound ID",
+			sid: StreamIdentifier{
+				Category: "category",
+				ID:       "123++abc",
+			},
+			expErr: ErrInvalidStreamID,
+		},
 		{
 			name: "valid",
 			sid: StreamIdentifier{
@@ -92,6 +126,13 @@ func Test_StreamIdentifier_validate(t *testing.T) {
 				ID:       "123-abc",
 			},
 		},
+		{
+			name: "valid with category types and compound ID",
+			sid: StreamIdentifier{
+				Category: "category:command+position",
+				ID:       "123+abc",
+			},
+		},
 	}
 
 	for _, tc := range testcases {
@@ -105,6 +146,186 @@ func Test_StreamIdentifier_validate(t *testing.T) {
 	}
 }
 
+func Test_parseStreamName(t *testing.T) {
+	testcases := []struct {
+		name     string
+		stream   string
+		expected StreamIdentifier
+	}{
+		{
+			name:     "category and ID",
+			stream:   "account-123",
+			expected: StreamIdentifier{Category: "account", ID: "123"},
+		},
+		{
+			name:     "ID containing separator",
+			stream:   "account-123-abc",
+			expected: StreamIdentifier{Category: "account", ID: "123-abc"},
+		},
+		{
+			name:     "category type",
+			stream:   "account:command-123",
+			expected: StreamIdentifier{Category: "account:command", ID: "123"},
+		},
+		{
+			name:     "compound ID",
+			stream:   "account-123+456",
+			expected: StreamIdentifier{Category: "account", ID: "123+456"},
+		},
+		{
+			name:     "category only",
+			stream:   "account",
+			expected: StreamIdentifier{Category: "account"},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			sid := parseStreamName(tc.stream)
+			if sid != tc.expected {
+				t.Fatalf("expected %+v, actual %+v", tc.expected, sid)
+			} else if tc.expected.ID != "" && sid.String() != tc.stream {
+				t.Fatalf("expected %s, actual %s", tc.stream, sid.String())
+			}
+		})
+	}
+}
+
+func Test_ParseStreamName(t *testing.T) {
+	testcases := []struct {
+		name       string
+		stream     string
+		category   string
+		types      []string
+		ids        []string
+		cardinalID string
+		isCategory bool
+		expErr     error
+	}{
+		{
+			name:       "stream",
+			stream:     "account-123",
+			category:   "account",
+			ids:        []string{"123"},
+			cardinalID: "123",
+		},
+		{
+			name:       "category types and compound ID",
+			stream:     "account:command+position-123+456",
+			category:   "account",
+			types:      []string{"command", "position"},
+			ids:        []string{"123", "456"},
+			cardinalID: "123",
+		},
+		{
+			name:       "category",
+			stream:     "account:command",
+			category:   "account",
+			types:      []string{"command"},
+			isCategory: true,
+		},
+		{
+			name:   "blank",
+			stream: "",
+			expErr: ErrMissingCategory,
+		},
+		{
+			name:   "missing ID",
+			stream: "account-",
+			expErr: ErrMissingStreamID,
+		},
+		{
+			name:   "blank compound ID",
+			stream: "account-123+",
+			expErr: ErrInvalidStreamID,
+		},
+		{
+			name:   "blank category type",
+			stream: "account:-123",
+			expErr: ErrInvalidCategoryType,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			sid, err := ParseStreamName(tc.stream)
+			if !errors.Is(err, tc.expErr) {
+				t.Fatalf("expected %v, actual %v", tc.expErr, err)
+			} else if err != nil {
+				return
+			}
+
+			if sid.String() != tc.stream {
+				t.Fatalf("expected %s, actual %s", tc.stream, sid.String())
+			} else if sid.EntityCategory() != tc.category {
+				t.Fatalf("expected category %s, actual %s", tc.category, sid.EntityCategory())
+			} else if !reflect.DeepEqual(sid.Types(), tc.types) {
+				t.Fatalf("expected types %v, actual %v", tc.types, sid.Types())
+			} else if !reflect.DeepEqual(sid.IDs(), tc.ids) {
+				t.Fatalf("expected IDs %v, actual %v", tc.ids, sid.IDs())
+			} else if sid.CardinalID() != tc.cardinalID {
+				t.Fatalf("expected cardinal ID %s, actual %s", tc.cardinalID, sid.CardinalID())
+			} else if sid.IsCategory() != tc.isCategory {
+				t.Fatalf("expected is category %v, actual %v", tc.isCategory, sid.IsCategory())
+			}
+		})
+	}
+}
+
+func Test_NewStreamIdentifier(t *testing.T) {
+	sid := NewStreamIdentifier("account", []string{"command", "position"}, "123", "456")
+
+	expected := "account:command+position-123+456"
+	if sid.String() != expected {
+		t.Fatalf("expected %s, actual %s", expected, sid.String())
+	} else if err := sid.validate(); err != nil {
+		t.Fatalf("unexpected validation error: %s", err)
+	}
+
+	category := NewStreamIdentifier("account", nil)
+	if category.String() != "account" || !category.IsCategory() {
+		t.Fatalf("expected category account, actual %s", category.String())
+	}
+}
+
+type rowScanner []interface{}
+
+func (r rowScanner) Scan(dest ...interface{}) error {
+	for i, d := range dest {
+		switch d := d.(type) {
+		case *string:
+			*d = r[i].(string)
+		case *int64:
+			*d = r[i].(int64)
+		case *[]byte:
+			*d = r[i].([]byte)
+		case *time.Time:
+			*d = r[i].(time.Time)
+		}
+	}
+
+	return nil
+}
+
+func Test_deserialiseMessage(t *testing.T) {
+	now := time.Now()
+	row := rowScanner{"someID", "account:command-123+456", "SomeType", int64(1), int64(10), []byte(`"data"`), []byte("null"), now}
+
+	msg, err := deserialiseMessage(row)
+	if err != nil {
+		t.Fatalf("unexpected error deserialising message: %s", err)
+	}
+
+	expected := StreamIdentifier{Category: "account:command", ID: "123+456"}
+	if msg.Stream != expected {
+		t.Fatalf("expected stream %+v, actual %+v", expected, msg.Stream)
+	} else if msg.Version != 1 || msg.GlobalPosition != 10 || !msg.Timestamp.Equal(now) {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+}
+
 func Test_Message_Unmarshal(t *testing.T) {
 	data := "some data"
 	metadata := "some metadata"
@@ -0,0 +1,147 @@
+package gomdb
+
+import (
+	"context"
+	"sync"
+)
+
+// pendingMessage is a message that has been dispatched to a worker. next is
+// the subscription position once the message has been handled.
+type pendingMessage struct {
+	msg  *Message
+	next int64
+	done bool
+}
+
+// workerResult is the outcome of a worker handling a message.
+type workerResult struct {
+	pending *pendingMessage
+	err     error
+}
+
+// workerPool handles messages on a number of worker goroutines. Messages from
+// the same stream are always handled by the same worker, so they are handled
+// in order. Positions are committed in the order messages were dispatched,
+// and only once every earlier message has been handled.
+type workerPool struct {
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	inputs  []chan *pendingMessage
+	results chan workerResult
+	// pending holds dispatched messages that have not been committed, in
+	// dispatch order.
+	pending []*pendingMessage
+	commit  func(position, messages int64) error
+}
+
+// newWorkerPool starts the workers. The pool must be stopped once it is no
+// longer used.
+func newWorkerPool(ctx context.Context, workers, buffer int64, handle func(ctx context.Context, msg *Message) error, commit func(position, messages int64) error) *workerPool {
+	ctx, cancel := context.WithCancel(ctx)
+
+	pool := &workerPool{
+		cancel:  cancel,
+		inputs:  make([]chan *pendingMessage, workers),
+		results: make(chan workerResult, buffer),
+		commit:  commit,
+	}
+
+	for i := range pool.inputs {
+		pool.inputs[i] = make(chan *pendingMessage, buffer)
+		pool.wg.Add(1)
+
+		go func(input <-chan *pendingMessage) {
+			defer pool.wg.Done()
+
+			for p := range input {
+				if ctx.Err() != nil {
+					return
+				}
+
+				result := workerResult{pending: p, err: handle(ctx, p.msg)}
+
+				select {
+				case pool.results <- result:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(pool.inputs[i])
+	}
+
+	return pool
+}
+
+// dispatch passes the message to the worker for its stream, completing
+// results while the worker is busy. next is the subscription position once
+// the message has been handled.
+func (pool *workerPool) dispatch(ctx context.Context, msg *Message, next int64) error {
+	p := &pendingMessage{msg: msg, next: next}
+	pool.pending = append(pool.pending, p)
+	input := pool.inputs[ConsumerGroupMemberFor(msg.Stream, int64(len(pool.inputs)))]
+
+	for {
+		select {
+		case input <- p:
+			return nil
+		case result := <-pool.results:
+			if err := pool.complete(result); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// complete marks the result's message as handled, and commits the position
+// of every handled message that has no unfinished message before it. If the
+// message could not be handled then the error is returned, leaving the
+// position at the first unfinished message.
+func (pool *workerPool) complete(result workerResult) error {
+	if result.err != nil {
+		return result.err
+	}
+
+	result.pending.done = true
+
+	for len(pool.pending) > 0 && pool.pending[0].done {
+		if err := pool.commit(pool.pending[0].next, 1); err != nil {
+			return err
+		}
+
+		pool.pending[0] = nil
+		pool.pending = pool.pending[1:]
+	}
+
+	return nil
+}
+
+// wait completes results until no more than limit messages are pending.
+func (pool *workerPool) wait(ctx context.Context, limit int) error {
+	for len(pool.pending) > limit {
+		select {
+		case result := <-pool.results:
+			if err := pool.complete(result); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// stop cancels the workers and waits for them to exit. Results that have not
+// been completed are discarded, so their messages will be handled again if
+// the subscription is resumed from its recorded position.
+func (pool *workerPool) stop() {
+	pool.cancel()
+
+	for _, input := range pool.inputs {
+		close(input)
+	}
+
+	pool.wg.Wait()
+}
@@ -0,0 +1,145 @@
+package gomdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testStreamMessages returns n messages spread across the specified number of
+// streams, with versions equal to their global positions.
+func testStreamMessages(n, streams int) []*Message {
+	msgs := make([]*Message, n)
+	for i := range msgs {
+		msgs[i] = &Message{
+			ID:             "someID",
+			Stream:         StreamIdentifier{Category: "test", ID: fmt.Sprint(i % streams)},
+			Version:        int64(i),
+			GlobalPosition: int64(i),
+		}
+	}
+
+	return msgs
+}
+
+// waitForPosition waits for the subscription to reach the position.
+func waitForPosition(t *testing.T, sub *Subscription, position int64) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for sub.Position() != position {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected position %v, actual %v", position, sub.Position())
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func Test_runSubscription_workers(t *testing.T) {
+	t.Parallel()
+
+	t.Run("handles streams in order", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		sub := newSubscription(cancel, 0)
+
+		var (
+			mu   sync.Mutex
+			last = map[StreamIdentifier]int64{}
+		)
+
+		loop := testLoop(testStreamMessages(30, 3), 4)
+		loop.workers = 4
+		loop.handleMessage = func(msg *Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if prev, ok := last[msg.Stream]; ok && prev > msg.Version {
+				t.Errorf("stream %s handled %v after %v", msg.Stream, msg.Version, prev)
+			}
+			last[msg.Stream] = msg.Version
+
+			return nil
+		}
+
+		go (&Client{}).runSubscription(ctx, sub, loop)
+
+		waitForPosition(t, sub, 30)
+		sub.Stop()
+
+		if err := sub.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("position waits for unfinished message", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		sub := newSubscription(cancel, 0)
+		release := make(chan struct{})
+		handled := sync.WaitGroup{}
+		handled.Add(8)
+
+		// stream 2 blocks until released.
+		loop := testLoop(testStreamMessages(10, 5), 10)
+		loop.workers = 5
+		loop.handleMessage = func(msg *Message) error {
+			if msg.Stream.ID == "2" {
+				<-release
+			} else {
+				handled.Done()
+			}
+
+			return nil
+		}
+
+		go (&Client{}).runSubscription(ctx, sub, loop)
+
+		handled.Wait()
+		time.Sleep(10 * time.Millisecond)
+
+		if sub.Position() != 2 {
+			t.Fatalf("expected position 2, actual %v", sub.Position())
+		}
+
+		close(release)
+		waitForPosition(t, sub, 10)
+		sub.Stop()
+
+		if err := sub.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("stops on failure", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		sub := newSubscription(cancel, 0)
+		errHandler := errors.New("handler failed")
+
+		loop := testLoop(testStreamMessages(20, 4), 20)
+		loop.workers = 4
+		loop.handleMessage = func(msg *Message) error {
+			if msg.Version == 5 {
+				return errHandler
+			}
+
+			return nil
+		}
+
+		go (&Client{}).runSubscription(ctx, sub, loop)
+
+		if err := sub.Wait(); !errors.Is(err, errHandler) {
+			t.Fatalf("expected %v, actual %v", errHandler, err)
+		} else if sub.Position() > 5 {
+			t.Fatalf("expected position to stop before the failed message, actual %v", sub.Position())
+		}
+	})
+}